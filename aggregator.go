@@ -0,0 +1,172 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the latency
+// histogram buckets used by NewStatsAggregator by default.
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// StatsAggregator is a StatsHandler aggregating the calls of each
+// method in memory, until they are read with Flush. Call Flush
+// periodically to export the statistics, e.g. from a time.Ticker.
+type StatsAggregator struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	methods map[string]*MethodStats
+}
+
+// MethodStats holds the statistics aggregated for a method.
+type MethodStats struct {
+	// Calls is the number of calls that finished.
+	Calls int
+	// Codes holds the number of calls that finished with each code.
+	Codes map[StatusCode]int
+
+	// LatencyBuckets are the upper bounds of the latency histogram
+	// buckets, and LatencyCounts the number of calls in each bucket.
+	// The last count is of the calls slower than every bucket.
+	LatencyBuckets []time.Duration
+	LatencyCounts  []int
+	// TotalLatency is the sum of the latencies of the calls.
+	TotalLatency time.Duration
+
+	// The number and total Length of the messages sent and received.
+	SentMessages     int
+	SentBytes        int64
+	ReceivedMessages int
+	ReceivedBytes    int64
+}
+
+// Errors returns the number of calls that did not finish with Ok.
+func (m *MethodStats) Errors() int {
+	return m.Calls - m.Codes[Ok]
+}
+
+// MeanLatency returns the mean latency of the calls.
+func (m *MethodStats) MeanLatency() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+
+	return m.TotalLatency / time.Duration(m.Calls)
+}
+
+// NewStatsAggregator returns a StatsAggregator with the latency
+// histogram buckets, or DefaultLatencyBuckets if none are given.
+func NewStatsAggregator(buckets ...time.Duration) *StatsAggregator {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	sorted := append([]time.Duration(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &StatsAggregator{
+		buckets: sorted,
+		methods: map[string]*MethodStats{},
+	}
+}
+
+// Flush returns the statistics of each method aggregated since
+// the previous call to Flush, and resets them.
+func (a *StatsAggregator) Flush() map[string]*MethodStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	methods := a.methods
+	a.methods = map[string]*MethodStats{}
+
+	return methods
+}
+
+// method returns the statistics of the method.
+// It must be called with a.mu held.
+func (a *StatsAggregator) method(name string) *MethodStats {
+	m, ok := a.methods[name]
+	if !ok {
+		m = &MethodStats{
+			Codes:          map[StatusCode]int{},
+			LatencyBuckets: a.buckets,
+			LatencyCounts:  make([]int, len(a.buckets)+1),
+		}
+		a.methods[name] = m
+	}
+
+	return m
+}
+
+// Begin implements StatsHandler.
+func (a *StatsAggregator) Begin(*RPCInfo) {}
+
+// OutHeader implements StatsHandler.
+func (a *StatsAggregator) OutHeader(*RPCInfo, Metadata) {}
+
+// OutPayload implements StatsHandler.
+func (a *StatsAggregator) OutPayload(info *RPCInfo, p *PayloadStats) {
+	a.mu.Lock()
+	m := a.method(info.Method)
+	m.SentMessages++
+	m.SentBytes += int64(p.Length)
+	a.mu.Unlock()
+}
+
+// InHeader implements StatsHandler.
+func (a *StatsAggregator) InHeader(*RPCInfo, Metadata) {}
+
+// InPayload implements StatsHandler.
+func (a *StatsAggregator) InPayload(info *RPCInfo, p *PayloadStats) {
+	a.mu.Lock()
+	m := a.method(info.Method)
+	m.ReceivedMessages++
+	m.ReceivedBytes += int64(p.Length)
+	a.mu.Unlock()
+}
+
+// End implements StatsHandler.
+func (a *StatsAggregator) End(info *RPCInfo, end *EndStats) {
+	latency := end.EndTime.Sub(info.BeginTime)
+	bucket := sort.Search(len(a.buckets), func(i int) bool { return latency <= a.buckets[i] })
+
+	a.mu.Lock()
+	m := a.method(info.Method)
+	m.Calls++
+	m.Codes[end.Code]++
+	m.LatencyCounts[bucket]++
+	m.TotalLatency += latency
+	a.mu.Unlock()
+}
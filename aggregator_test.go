@@ -0,0 +1,386 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+func TestStatsAggregatorLatencyBuckets(t *testing.T) {
+	// The buckets are sorted by NewStatsAggregator
+	a := grpcweb.NewStatsAggregator(20*time.Millisecond, 10*time.Millisecond)
+	begin := time.Now()
+	info := &grpcweb.RPCInfo{Method: "/test.Service/Method", BeginTime: begin}
+
+	latencies := []time.Duration{
+		0,
+		10 * time.Millisecond, // A latency equal to a bound is in its bucket
+		10*time.Millisecond + 1,
+		20 * time.Millisecond,
+		20*time.Millisecond + 1, // Slower than every bucket
+		time.Hour,
+	}
+	var total time.Duration
+	for _, l := range latencies {
+		a.End(info, &grpcweb.EndStats{Code: grpcweb.Ok, EndTime: begin.Add(l)})
+		total += l
+	}
+
+	m := a.Flush()[info.Method]
+	if m == nil {
+		t.Fatal("no statistics for the method")
+	}
+	wantBuckets := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+	if len(m.LatencyBuckets) != 2 || m.LatencyBuckets[0] != wantBuckets[0] || m.LatencyBuckets[1] != wantBuckets[1] {
+		t.Errorf("got buckets %v, want %v", m.LatencyBuckets, wantBuckets)
+	}
+	wantCounts := []int{2, 2, 2}
+	for i, c := range wantCounts {
+		if m.LatencyCounts[i] != c {
+			t.Errorf("got counts %v, want %v", m.LatencyCounts, wantCounts)
+			break
+		}
+	}
+	if m.Calls != len(latencies) {
+		t.Errorf("got %d calls, want %d", m.Calls, len(latencies))
+	}
+	if m.TotalLatency != total {
+		t.Errorf("got total latency %v, want %v", m.TotalLatency, total)
+	}
+	if want := total / time.Duration(len(latencies)); m.MeanLatency() != want {
+		t.Errorf("got mean latency %v, want %v", m.MeanLatency(), want)
+	}
+}
+
+func TestStatsAggregatorDefaultBuckets(t *testing.T) {
+	a := grpcweb.NewStatsAggregator()
+	info := &grpcweb.RPCInfo{Method: "/test.Service/Method", BeginTime: time.Now()}
+	a.End(info, &grpcweb.EndStats{Code: grpcweb.Ok, EndTime: info.BeginTime.Add(time.Second)})
+
+	m := a.Flush()[info.Method]
+	if len(m.LatencyCounts) != len(grpcweb.DefaultLatencyBuckets)+1 {
+		t.Fatalf("got %d counts, want %d", len(m.LatencyCounts), len(grpcweb.DefaultLatencyBuckets)+1)
+	}
+	for i, b := range grpcweb.DefaultLatencyBuckets {
+		want := 0
+		if b == time.Second {
+			want = 1
+		}
+		if m.LatencyCounts[i] != want {
+			t.Errorf("bucket %v has %d calls, want %d", b, m.LatencyCounts[i], want)
+		}
+	}
+}
+
+func TestStatsAggregatorCodes(t *testing.T) {
+	a := grpcweb.NewStatsAggregator()
+	begin := time.Now()
+	for method, codes := range map[string][]grpcweb.StatusCode{
+		"/test.Service/A": {grpcweb.Ok, grpcweb.Ok, grpcweb.NotFound, grpcweb.Unavailable, grpcweb.Unavailable},
+		"/test.Service/B": {grpcweb.Ok},
+	} {
+		info := &grpcweb.RPCInfo{Method: method, BeginTime: begin}
+		for _, c := range codes {
+			a.End(info, &grpcweb.EndStats{Code: c, EndTime: begin})
+		}
+	}
+
+	stats := a.Flush()
+	m := stats["/test.Service/A"]
+	if m.Calls != 5 || m.Errors() != 3 {
+		t.Errorf("got %d calls and %d errors, want 5 and 3", m.Calls, m.Errors())
+	}
+	for c, want := range map[grpcweb.StatusCode]int{grpcweb.Ok: 2, grpcweb.NotFound: 1, grpcweb.Unavailable: 2, grpcweb.Internal: 0} {
+		if m.Codes[c] != want {
+			t.Errorf("got %d calls with %v, want %d", m.Codes[c], c, want)
+		}
+	}
+	if m := stats["/test.Service/B"]; m.Calls != 1 || m.Errors() != 0 || m.Codes[grpcweb.Ok] != 1 {
+		t.Errorf("got %d calls and %d errors for B, want 1 and 0", m.Calls, m.Errors())
+	}
+}
+
+func TestStatsAggregatorPayloads(t *testing.T) {
+	a := grpcweb.NewStatsAggregator()
+	info := &grpcweb.RPCInfo{Method: "/test.Service/Method"}
+	a.OutPayload(info, &grpcweb.PayloadStats{Length: 3, WireLength: 3})
+	a.InPayload(info, &grpcweb.PayloadStats{Length: 10, WireLength: 4})
+	a.InPayload(info, &grpcweb.PayloadStats{Length: 5, WireLength: 5})
+
+	m := a.Flush()[info.Method]
+	if m.SentMessages != 1 || m.SentBytes != 3 {
+		t.Errorf("got %d messages and %d bytes sent, want 1 and 3", m.SentMessages, m.SentBytes)
+	}
+	if m.ReceivedMessages != 2 || m.ReceivedBytes != 15 {
+		t.Errorf("got %d messages and %d bytes received, want 2 and 15", m.ReceivedMessages, m.ReceivedBytes)
+	}
+	if m.Calls != 0 || m.MeanLatency() != 0 {
+		t.Errorf("got %d calls with a mean latency of %v before any ended, want none", m.Calls, m.MeanLatency())
+	}
+}
+
+func TestStatsAggregatorFlush(t *testing.T) {
+	a := grpcweb.NewStatsAggregator()
+	info := &grpcweb.RPCInfo{Method: "/test.Service/Method", BeginTime: time.Now()}
+	a.End(info, &grpcweb.EndStats{Code: grpcweb.Ok, EndTime: info.BeginTime})
+
+	first := a.Flush()
+	if m := first[info.Method]; m == nil || m.Calls != 1 {
+		t.Fatalf("got %+v, want a call", first)
+	}
+	if second := a.Flush(); len(second) != 0 {
+		t.Errorf("got %+v after Flush, want no statistics", second)
+	}
+
+	// Calls after Flush don't change the flushed statistics
+	a.End(info, &grpcweb.EndStats{Code: grpcweb.NotFound, EndTime: info.BeginTime})
+	if m := first[info.Method]; m.Calls != 1 || m.Codes[grpcweb.NotFound] != 0 {
+		t.Errorf("flushed statistics changed to %+v", m)
+	}
+	if m := a.Flush()[info.Method]; m == nil || m.Calls != 1 || m.Codes[grpcweb.NotFound] != 1 || m.LatencyCounts[0] != 1 {
+		t.Errorf("got %+v, want only the call made after the previous Flush", m)
+	}
+}
+
+// recordingHandler is a StatsHandler recording the calls
+// that begin and end, and the order of their events.
+type recordingHandler struct {
+	mu     sync.Mutex
+	events map[*grpcweb.RPCInfo][]string
+	ends   chan *grpcweb.RPCInfo
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{
+		events: map[*grpcweb.RPCInfo][]string{},
+		ends:   make(chan *grpcweb.RPCInfo, 10),
+	}
+}
+
+func (h *recordingHandler) record(info *grpcweb.RPCInfo, event string) {
+	h.mu.Lock()
+	h.events[info] = append(h.events[info], event)
+	h.mu.Unlock()
+}
+
+func (h *recordingHandler) Begin(info *grpcweb.RPCInfo) {
+	h.record(info, "Begin")
+}
+
+func (h *recordingHandler) OutHeader(info *grpcweb.RPCInfo, _ grpcweb.Metadata) {
+	h.record(info, "OutHeader")
+}
+
+func (h *recordingHandler) OutPayload(info *grpcweb.RPCInfo, _ *grpcweb.PayloadStats) {
+	h.record(info, "OutPayload")
+}
+
+func (h *recordingHandler) InHeader(info *grpcweb.RPCInfo, _ grpcweb.Metadata) {
+	h.record(info, "InHeader")
+}
+
+func (h *recordingHandler) InPayload(info *grpcweb.RPCInfo, _ *grpcweb.PayloadStats) {
+	h.record(info, "InPayload")
+}
+
+func (h *recordingHandler) End(info *grpcweb.RPCInfo, end *grpcweb.EndStats) {
+	h.record(info, "End "+end.Code.String())
+	h.ends <- info
+}
+
+func TestStatsHandlerCalls(t *testing.T) {
+	for _, tt := range []struct {
+		name                       string
+		response                   *grpcweb.FakeResponse
+		call                       func(c *grpcweb.GRPCWebClientBase) error
+		clientStream, serverStream bool
+		want                       []string
+	}{
+		{
+			name:     "unary",
+			response: &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				_, err := c.RPCCall(method, message("req"))
+				return err
+			},
+			want: []string{"Begin", "OutHeader", "OutPayload", "InHeader", "InPayload", "End Ok"},
+		},
+		{
+			name:     "unary error",
+			response: &grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.NotFound}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				_, err := c.RPCCall(method, message("req"))
+				if err == nil {
+					return &grpcweb.Error{Code: grpcweb.Internal, Message: "call succeeded"}
+				}
+				return nil
+			},
+			want: []string{"Begin", "OutHeader", "OutPayload", "InHeader", "End NotFound"},
+		},
+		{
+			name:     "server stream",
+			response: &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("one"), message("two")}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				stream, err := c.ServerStreaming(method, message("req"))
+				if err != nil {
+					return err
+				}
+				return drain(stream)
+			},
+			serverStream: true,
+			want:         []string{"Begin", "OutHeader", "OutPayload", "InHeader", "InPayload", "InPayload", "End Ok"},
+		},
+		{
+			name:     "server stream closed",
+			response: &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("one"), message("two")}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				stream, err := c.ServerStreaming(method, message("req"), grpcweb.WithStreamBuffer(1, grpcweb.BlockOnOverflow))
+				if err != nil {
+					return err
+				}
+				stream.Close()
+				return nil
+			},
+			serverStream: true,
+		},
+		{
+			name:     "client stream",
+			response: &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				stream, err := c.ClientStreaming(method)
+				if err != nil {
+					return err
+				}
+				for _, msg := range []string{"one", "two"} {
+					if err := stream.Send(message(msg)); err != nil {
+						return err
+					}
+				}
+				_, err = stream.CloseAndRecv()
+				return err
+			},
+			clientStream: true,
+			want:         []string{"Begin", "OutHeader", "OutPayload", "OutPayload", "InHeader", "InPayload", "End Ok"},
+		},
+		{
+			name:     "bidi stream",
+			response: &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("one"), message("two")}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				stream, err := c.BidiStreaming(method)
+				if err != nil {
+					return err
+				}
+				if err := stream.Send(message("req")); err != nil {
+					return err
+				}
+				if err := stream.CloseSend(); err != nil {
+					return err
+				}
+				return drain(stream)
+			},
+			clientStream: true,
+			serverStream: true,
+		},
+		{
+			name:     "bidi stream closed",
+			response: &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("one"), message("two")}},
+			call: func(c *grpcweb.GRPCWebClientBase) error {
+				stream, err := c.BidiStreaming(method, grpcweb.WithStreamBuffer(1, grpcweb.BlockOnOverflow))
+				if err != nil {
+					return err
+				}
+				stream.Close()
+				return nil
+			},
+			clientStream: true,
+			serverStream: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := grpcweb.NewFakeTransport()
+			transport.Script(method, tt.response)
+			h := newRecordingHandler()
+			c := grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport), grpcweb.WithStatsHandler(h))
+
+			if err := tt.call(c); err != nil {
+				t.Fatal(err)
+			}
+
+			var info *grpcweb.RPCInfo
+			select {
+			case info = <-h.ends:
+			case <-time.After(5 * time.Second):
+				t.Fatal("End was not called")
+			}
+			select {
+			case <-h.ends:
+				t.Error("End was called twice")
+			default:
+			}
+
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if len(h.events) != 1 {
+				t.Fatalf("got events for %d calls, want 1", len(h.events))
+			}
+			events := h.events[info]
+			if events[0] != "Begin" {
+				t.Errorf("got events %v, want Begin first", events)
+			}
+			ends := 0
+			for _, e := range events {
+				if strings.HasPrefix(e, "End ") {
+					ends++
+				}
+			}
+			if ends != 1 || !strings.HasPrefix(events[len(events)-1], "End ") {
+				t.Errorf("got events %v, want a single End last", events)
+			}
+			// The order of the events of duplex streams depends on
+			// the scheduling of the goroutines sending and receiving.
+			if tt.want != nil && strings.Join(events, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got events %v, want %v", events, tt.want)
+			}
+			if info.Method != method || info.ClientStream != tt.clientStream || info.ServerStream != tt.serverStream {
+				t.Errorf("got RPCInfo %+v, want %s with ClientStream %v and ServerStream %v",
+					info, method, tt.clientStream, tt.serverStream)
+			}
+		})
+	}
+}
+
+// drain receives from the stream until it ends,
+// returning nil if it finished with Ok.
+func drain(stream grpcweb.ClientStream) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if err == grpcweb.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
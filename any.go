@@ -0,0 +1,86 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"strings"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+// Any is a google.protobuf.Any, a serialized message
+// along with a URL identifying the type of the message.
+type Any struct {
+	TypeURL string
+	Value   []byte
+}
+
+// MessageName returns the full name of the type of the
+// message, the part of the type URL after the last "/".
+func (a *Any) MessageName() string {
+	return a.TypeURL[strings.LastIndex(a.TypeURL, "/")+1:]
+}
+
+// UnmarshalTo deserializes the message into m. It does not check
+// that m is of the type identified by the type URL.
+func (a *Any) UnmarshalTo(m ProtoMessage) error {
+	return m.Deserialize(a.Value)
+}
+
+// Serialize marshals the Any to the protobuf wire format.
+func (a *Any) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := a.TypeURL; v != "" {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := a.Value; len(v) > 0 {
+		b.EncodeTag(2, wire.Bytes)
+		b.EncodeBytes(v)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals the Any from the protobuf wire format.
+func (a *Any) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			a.TypeURL, err = b.DecodeString()
+		case 2:
+			a.Value, err = b.DecodeBytes()
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
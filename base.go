@@ -20,6 +20,19 @@
 
 package grpcweb
 
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Default message size limits, as in gRPC. They can be changed
+// for all calls of a client with WithDefaultCallOptions.
+const (
+	DefaultMaxRecvMsgSize = 4 << 20
+	DefaultMaxSendMsgSize = math.MaxInt32
+)
+
 // ProtoMessage must be implemented by all generated proto structs
 type ProtoMessage interface {
 	Serialize() ([]byte, error)
@@ -30,13 +43,138 @@ type ProtoMessage interface {
 type ResponseCallback func(status *Status, resp []byte)
 
 // CallOption can be used to configure a call
-type CallOption func(*XHRNodeReadableStream)
+type CallOption func(*callInfo)
+
+// callInfo holds the configuration of a single call
+type callInfo struct {
+	headers    Metadata
+	bufferSize int
+	overflow   OverflowPolicy
+	header     *Metadata
+	trailer    *Metadata
+	compressor string
+	timeout    time.Duration
 
-// WithMetadata adds the metadata as headers to the request
+	maxRecvMsgSize int
+	maxSendMsgSize int
+}
+
+func newCallInfo(opts []CallOption) *callInfo {
+	c := &callInfo{
+		headers:        Metadata{},
+		bufferSize:     DefaultStreamBufferSize,
+		overflow:       BlockOnOverflow,
+		maxRecvMsgSize: DefaultMaxRecvMsgSize,
+		maxSendMsgSize: DefaultMaxSendMsgSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithMetadata adds the metadata as headers to the request.
+// Values are added to those of earlier options with the same key.
 func WithMetadata(m Metadata) CallOption {
-	return func(x *XHRNodeReadableStream) {
+	return func(c *callInfo) {
 		for k, v := range m {
-			x.xhr.SetRequestHeader(k, v)
+			c.headers.Append(k, v...)
 		}
 	}
 }
+
+// Header returns a CallOption that retrieves the response headers
+// of the call. For unary calls, header is filled in when the call
+// returns. For streams, it is filled in before the first message
+// or error is returned by Recv.
+func Header(header *Metadata) CallOption {
+	return func(c *callInfo) {
+		c.header = header
+	}
+}
+
+// Trailer returns a CallOption that retrieves the trailing metadata
+// sent with the status of the call. For unary calls, trailer is filled
+// in when the call returns. For streams, it is filled in before Recv
+// returns the terminal error.
+func Trailer(trailer *Metadata) CallOption {
+	return func(c *callInfo) {
+		c.trailer = trailer
+	}
+}
+
+func (c *callInfo) setHeader(m Metadata) {
+	if c.header != nil {
+		*c.header = m
+	}
+}
+
+func (c *callInfo) setTrailer(m Metadata) {
+	if c.trailer != nil {
+		*c.trailer = m
+	}
+}
+
+// WithTimeout aborts the call with DeadlineExceeded if it hasn't
+// finished within d, and sends the deadline to the server in the
+// grpc-timeout header. Each attempt of a retried call is given the
+// full timeout. Calls have no timeout by default; use
+// WithDefaultCallOptions to set one for all calls of a client.
+func WithTimeout(d time.Duration) CallOption {
+	return func(c *callInfo) {
+		c.timeout = d
+	}
+}
+
+// WithStreamBuffer sets the number of messages a server stream
+// buffers for the consumer, and the policy applied when the
+// buffer is full. Sizes smaller than 1 are treated as 1.
+func WithStreamBuffer(size int, policy OverflowPolicy) CallOption {
+	return func(c *callInfo) {
+		if size < 1 {
+			size = 1
+		}
+		c.bufferSize = size
+		c.overflow = policy
+	}
+}
+
+// MaxCallRecvMsgSize sets the largest response message the
+// call accepts, in bytes. Larger messages fail the call with
+// ResourceExhausted. The default is DefaultMaxRecvMsgSize.
+// A value of 0 or less removes the limit.
+func MaxCallRecvMsgSize(bytes int) CallOption {
+	return func(c *callInfo) {
+		c.maxRecvMsgSize = bytes
+	}
+}
+
+// MaxCallSendMsgSize sets the largest request message the
+// call sends, in bytes. Larger messages fail with
+// ResourceExhausted. The default is DefaultMaxSendMsgSize.
+// A value of 0 or less removes the limit.
+func MaxCallSendMsgSize(bytes int) CallOption {
+	return func(c *callInfo) {
+		c.maxSendMsgSize = bytes
+	}
+}
+
+// checkSendSize returns an error if msg is larger
+// than the maximum size of request messages.
+func (c *callInfo) checkSendSize(msg []byte) error {
+	if c.maxSendMsgSize > 0 && len(msg) > c.maxSendMsgSize {
+		return &Error{Code: ResourceExhausted, Message: "trying to send message larger than max (" +
+			strconv.Itoa(len(msg)) + " vs. " + strconv.Itoa(c.maxSendMsgSize) + ")"}
+	}
+
+	return nil
+}
+
+// errRecvSize is the error of a response message of
+// the size, larger than the maximum size max. The size
+// is unsigned since it may not fit in an int.
+func errRecvSize(size uint64, max int) error {
+	return &Error{Code: ResourceExhausted, Message: "received message larger than max (" +
+		strconv.FormatUint(size, 10) + " vs. " + strconv.Itoa(max) + ")"}
+}
@@ -0,0 +1,448 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"strconv"
+	"time"
+)
+
+// clientBase implements the functionality shared by all clients.
+type clientBase struct {
+	transport          Transport
+	encoding           Encoding
+	unaryInterceptors  []UnaryClientInterceptor
+	streamInterceptors []StreamClientInterceptor
+	unaryInt           UnaryClientInterceptor
+	streamInt          StreamClientInterceptor
+
+	retryPolicy         *RetryPolicy
+	methodRetryPolicies map[string]*RetryPolicy
+
+	logger       Logger
+	creds        PerRPCCredentials
+	statsHandler StatsHandler
+
+	// target is prepended to the endpoint of every call
+	target             string
+	defaultCallOptions []CallOption
+}
+
+// Client is implemented by all clients.
+// It is used by generated service clients.
+type Client interface {
+	RPCCall(endpoint string, request ProtoMessage, opts ...CallOption) ([]byte, error)
+	ServerStreaming(endpoint string, request ProtoMessage, opts ...CallOption) (ClientStream, error)
+	ClientStreaming(endpoint string, opts ...CallOption) (StreamWriter, error)
+	BidiStreaming(endpoint string, opts ...CallOption) (BidiStream, error)
+}
+
+// ClientOption can be used to configure a client
+type ClientOption func(*clientBase)
+
+// WithTransport sets the Transport used to make requests.
+func WithTransport(t Transport) ClientOption {
+	return func(c *clientBase) {
+		c.transport = t
+	}
+}
+
+// WithUnaryInterceptor adds interceptors that are called on every
+// RPCCall. Interceptors are called in the order they are added.
+func WithUnaryInterceptor(interceptors ...UnaryClientInterceptor) ClientOption {
+	return func(c *clientBase) {
+		c.unaryInterceptors = append(c.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptor adds interceptors that are called on every
+// ServerStreaming, ClientStreaming and BidiStreaming call.
+// Interceptors are called in the order they are added.
+func WithStreamInterceptor(interceptors ...StreamClientInterceptor) ClientOption {
+	return func(c *clientBase) {
+		c.streamInterceptors = append(c.streamInterceptors, interceptors...)
+	}
+}
+
+// WithDefaultCallOptions sets CallOptions applied to every call
+// made by the client, before the CallOptions of the call.
+func WithDefaultCallOptions(opts ...CallOption) ClientOption {
+	return func(c *clientBase) {
+		c.defaultCallOptions = append(c.defaultCallOptions, opts...)
+	}
+}
+
+// newClientBase applies the options to a
+// clientBase using the provided default transport.
+func newClientBase(transport Transport, opts []ClientOption) clientBase {
+	c := clientBase{
+		transport: transport,
+		logger:    defaultLogger(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	c.unaryInt = chainUnaryInterceptors(c.unaryInterceptors)
+	c.streamInt = chainStreamInterceptors(c.streamInterceptors)
+
+	return c
+}
+
+// callOptions returns the default call options
+// of the client followed by opts.
+func (c *clientBase) callOptions(opts []CallOption) []CallOption {
+	if len(c.defaultCallOptions) == 0 {
+		return opts
+	}
+
+	all := make([]CallOption, 0, len(c.defaultCallOptions)+len(opts))
+	all = append(all, c.defaultCallOptions...)
+	return append(all, opts...)
+}
+
+// RPCCall makes a request to the provided endpoint using the provided
+// request. It returns a byte representation of the response, or an error
+func (c *clientBase) RPCCall(endpoint string, request ProtoMessage, opts ...CallOption) ([]byte, error) {
+	opts = c.callOptions(opts)
+	if c.unaryInt != nil {
+		return c.unaryInt(endpoint, request, c.invoke, opts...)
+	}
+
+	return c.invoke(endpoint, request, opts...)
+}
+
+// ServerStreaming makes a request to the provided streaming endpoint
+// using the provided request. It returns a ClientStream for reading messages.
+// Messages are buffered as configured with WithStreamBuffer.
+func (c *clientBase) ServerStreaming(endpoint string, request ProtoMessage, opts ...CallOption) (ClientStream, error) {
+	opts = c.callOptions(opts)
+	if c.streamInt != nil {
+		return c.streamInt(endpoint, request, c.stream, opts...)
+	}
+
+	return c.stream(endpoint, request, opts...)
+}
+
+// invoke is the UnaryInvoker at the end of the interceptor chain.
+// It retries the call according to the retry policy of the method.
+func (c *clientBase) invoke(endpoint string, request ProtoMessage, opts ...CallOption) ([]byte, error) {
+	ci := newCallInfo(opts)
+	policy := c.retryPolicyFor(endpoint)
+	refreshed := false
+
+	for attempt := 1; ; attempt++ {
+		resp, trailer, creds, err := c.unaryAttempt(endpoint, request, ci)
+		if err == nil {
+			return resp, nil
+		}
+
+		// Retry once with refreshed credentials, without
+		// counting the attempt against the retry policy.
+		if !refreshed && c.refreshCredentials(creds, err) {
+			refreshed = true
+			attempt--
+			continue
+		}
+
+		delay, ok := policy.backoff(attempt, err, trailer)
+		if !ok {
+			return nil, err
+		}
+		c.logger.Fine(logEntry("retrying call", "method", endpoint, "attempt", strconv.Itoa(attempt+1), "delay", delay.String()))
+		time.Sleep(delay)
+	}
+}
+
+// unaryAttempt makes a single attempt at a unary call. It returns the
+// response, or the error and trailer of the failed attempt, and the
+// metadata added to the request by the credentials of the client.
+func (c *clientBase) unaryAttempt(endpoint string, request ProtoMessage, ci *callInfo) (resp []byte, trailer, creds Metadata, err error) {
+	stream, err := c.newStream(endpoint, request, ci, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	resp, err = recvUnary(stream)
+	return resp, stream.trailer, stream.creds, err
+}
+
+// recvUnary receives the single response message of a
+// call and waits for the status of the call.
+func recvUnary(stream ClientStream) ([]byte, error) {
+	// Block until we've received a reply
+	resp, err := stream.Recv()
+	if err == EOF {
+		return nil, &Error{Code: Internal, Message: "no response received"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for the status, which also fills in the trailer
+	_, err = stream.Recv()
+	if err == nil {
+		stream.Close()
+		return nil, &Error{Code: Internal, Message: "received more than one response"}
+	}
+	if err != EOF {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// stream is the Streamer at the end of the interceptor chain.
+// If the method has a retry policy, or the client has credentials,
+// the stream is retried until the first message has been received.
+func (c *clientBase) stream(endpoint string, request ProtoMessage, opts ...CallOption) (ClientStream, error) {
+	ci := newCallInfo(opts)
+	reader, err := c.newStream(endpoint, request, ci, true)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := c.retryPolicyFor(endpoint)
+	if policy == nil && c.creds == nil {
+		return reader, nil
+	}
+
+	return &retryStream{
+		policy:  policy,
+		refresh: c.refreshCredentials,
+		cur:     reader,
+		newStream: func() (*StreamReader, error) {
+			return c.newStream(endpoint, request, ci, true)
+		},
+	}, nil
+}
+
+// newStream sends the request and returns a StreamReader for the response,
+// which is a stream if serverStream is set.
+func (c *clientBase) newStream(endpoint string, request ProtoMessage, ci *callInfo, serverStream bool) (*StreamReader, error) {
+	reqData, err := request.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if err := ci.checkSendSize(reqData); err != nil {
+		return nil, err
+	}
+
+	header, creds, err := c.requestHeader(c.target+endpoint, ci)
+	if err != nil {
+		return nil, err
+	}
+	compressor, err := callCompressor(ci)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := c.beginStats(endpoint, false, serverStream)
+	stats.outHeader(header)
+	start := time.Now()
+	c.logger.Finer(logEntry("sending request", "method", endpoint, "bytes", strconv.Itoa(len(reqData))))
+	ts, err := c.transport.NewStream(&TransportRequest{
+		Endpoint:       c.target + endpoint,
+		Header:         header,
+		Body:           reqData,
+		Timeout:        ci.timeout,
+		Encoding:       c.encoding,
+		Compressor:     compressor,
+		MaxRecvMsgSize: ci.maxRecvMsgSize,
+	})
+	if err != nil {
+		c.logger.Fine(logEntry("call failed", "method", endpoint, "code", errorCode(err).String(), "elapsed", elapsedField(start), "error", err.Error()))
+		stats.end(err, nil)
+		return nil, err
+	}
+	stats.outPayload(len(reqData), len(reqData))
+
+	reader := c.readStream(endpoint, ci, ts, start, stats)
+	reader.creds = creds
+	return reader, nil
+}
+
+// ClientStreaming opens a client streaming call to the provided endpoint.
+// It returns a StreamWriter for sending messages and receiving the response.
+// It requires a Transport implementing StreamingTransport.
+func (c *clientBase) ClientStreaming(endpoint string, opts ...CallOption) (StreamWriter, error) {
+	stream, err := c.duplexStream(endpoint, false, c.callOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{BidiStream: stream}, nil
+}
+
+// BidiStreaming opens a bidirectional streaming call to the provided
+// endpoint. It returns a BidiStream for sending and receiving messages.
+// It requires a Transport implementing StreamingTransport.
+func (c *clientBase) BidiStreaming(endpoint string, opts ...CallOption) (BidiStream, error) {
+	return c.duplexStream(endpoint, true, c.callOptions(opts))
+}
+
+// duplexStream opens a client or bidirectional streaming call through
+// the stream interceptors, which are called with a nil request. If the
+// interceptors wrap the ClientStream of the call, messages are still
+// sent on the stream created by the Streamer.
+func (c *clientBase) duplexStream(endpoint string, serverStream bool, opts []CallOption) (BidiStream, error) {
+	if c.streamInt == nil {
+		stream, err := c.newDuplexStream(endpoint, newCallInfo(opts), serverStream)
+		if err != nil {
+			return nil, err
+		}
+		return stream, nil
+	}
+
+	var sender *bidiStream
+	streamer := func(method string, _ ProtoMessage, opts ...CallOption) (ClientStream, error) {
+		stream, err := c.newDuplexStream(method, newCallInfo(opts), serverStream)
+		if err != nil {
+			return nil, err
+		}
+		sender = stream
+		return stream, nil
+	}
+
+	cs, err := c.streamInt(endpoint, nil, streamer, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if stream, ok := cs.(BidiStream); ok {
+		return stream, nil
+	}
+	if sender == nil {
+		cs.Close()
+		return nil, &Error{Code: Internal, Message: "stream interceptor returned a stream that can't send"}
+	}
+
+	return &interceptedStream{ClientStream: cs, sender: sender}, nil
+}
+
+// newDuplexStream opens a client or bidirectional streaming call.
+// If it fails with Unauthenticated, the credentials of the client are
+// refreshed, but the call isn't retried, as the messages sent on it
+// can't be replayed.
+func (c *clientBase) newDuplexStream(endpoint string, ci *callInfo, serverStream bool) (*bidiStream, error) {
+	st, ok := c.transport.(StreamingTransport)
+	if !ok {
+		return nil, &Error{Code: Unimplemented, Message: "transport does not support streaming requests"}
+	}
+
+	header, creds, err := c.requestHeader(c.target+endpoint, ci)
+	if err != nil {
+		return nil, err
+	}
+	compressor, err := callCompressor(ci)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := c.beginStats(endpoint, true, serverStream)
+	stats.outHeader(header)
+	ts, err := st.NewDuplexStream(&TransportRequest{
+		Endpoint:       c.target + endpoint,
+		Header:         header,
+		Encoding:       c.encoding,
+		Compressor:     compressor,
+		Timeout:        ci.timeout,
+		MaxRecvMsgSize: ci.maxRecvMsgSize,
+	})
+	if err != nil {
+		stats.end(err, nil)
+		return nil, err
+	}
+
+	reader := c.readStream(endpoint, ci, ts, time.Now(), stats)
+	reader.creds = creds
+	return &bidiStream{
+		StreamReader: reader,
+		ts:           ts,
+		ci:           ci,
+		stats:        stats,
+		refresh:      c.refreshCredentials,
+	}, nil
+}
+
+// readStream returns a StreamReader for reading the messages
+// received on the TransportStream of a call to the endpoint
+// started at start, reporting them to stats.
+func (c *clientBase) readStream(endpoint string, ci *callInfo, ts TransportStream, start time.Time, stats *callStats) *StreamReader {
+	reader := newStreamReader(ci.bufferSize, ci.overflow, ts.Close)
+
+	// Frames are read on a separate goroutine, so that every
+	// message is delivered before the terminal status.
+	go func() {
+		received := 0
+		f, err := ts.Recv()
+		ci.setHeader(ts.Header())
+		if err == nil {
+			stats.inHeader(ts.Header())
+		}
+		for ; err == nil; f, err = ts.Recv() {
+			if f.Status != nil {
+				c.logger.Fine(logEntry("call finished", "method", endpoint, "code", f.Status.Code.String(),
+					"elapsed", elapsedField(start), "bytes", strconv.Itoa(received)))
+				reader.trailer = f.Status.Metadata
+				ci.setTrailer(f.Status.Metadata)
+				if f.Status.Code != Ok {
+					err := &Error{Code: f.Status.Code, Message: f.Status.Message, Details: f.Status.Details()}
+					stats.end(err, f.Status.Metadata)
+					reader.finish(err)
+					return
+				}
+
+				// Success!
+				stats.end(EOF, f.Status.Metadata)
+				reader.finish(EOF)
+				return
+			}
+
+			received += len(f.Message)
+			c.logger.Finer(logEntry("received message", "method", endpoint, "bytes", strconv.Itoa(len(f.Message))))
+			if ci.maxRecvMsgSize > 0 && len(f.Message) > ci.maxRecvMsgSize {
+				// Not all transports enforce the limit
+				err = errRecvSize(uint64(len(f.Message)), ci.maxRecvMsgSize)
+				ts.Close()
+				break
+			}
+			wireLength := len(f.Message)
+			if f.Compressed {
+				if f.Message, err = decompress(ts.Header(), f.Message, ci.maxRecvMsgSize); err != nil {
+					ts.Close()
+					break
+				}
+			}
+			stats.inPayload(len(f.Message), wireLength)
+			if !reader.push(f.Message) {
+				stats.end(reader.result(), nil)
+				return
+			}
+		}
+
+		c.logger.Fine(logEntry("call failed", "method", endpoint, "code", errorCode(err).String(),
+			"elapsed", elapsedField(start), "error", err.Error()))
+		stats.end(err, nil)
+		reader.finish(err)
+	}()
+
+	return reader
+}
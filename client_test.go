@@ -0,0 +1,61 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+func TestCallTimeout(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithDefaultCallOptions(grpcweb.WithTimeout(time.Second)),
+	)
+
+	if _, err := c.RPCCall(method, message("req")); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := c.ServerStreaming(method, message("req"), grpcweb.WithTimeout(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	bidi, err := c.BidiStreaming(method, grpcweb.WithTimeout(3*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bidi.Close()
+
+	requests := transport.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		if got := requests[i].Timeout; got != want {
+			t.Errorf("request %d: got timeout %v, want %v", i, got, want)
+		}
+	}
+}
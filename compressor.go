@@ -0,0 +1,190 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compression headers
+const (
+	encodingHeader       = "grpc-encoding"
+	acceptEncodingHeader = "grpc-accept-encoding"
+)
+
+// Compressor compresses and decompresses messages. Compressors are
+// registered with RegisterCompressor and identified by their Name,
+// which is sent in the grpc-encoding header.
+type Compressor interface {
+	// Compress returns a writer that compresses the bytes written
+	// to it into w. The writer is closed once the message is written.
+	Compress(w io.Writer) (io.WriteCloser, error)
+	// Decompress returns a reader that decompresses r.
+	Decompress(r io.Reader) (io.Reader, error)
+	// Name returns the name of the compression algorithm.
+	Name() string
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{}
+)
+
+func init() {
+	RegisterCompressor(gzipCompressor{})
+}
+
+// RegisterCompressor registers the Compressor under its Name, replacing
+// any Compressor registered with the same name. The names of all
+// registered Compressors are sent in the grpc-accept-encoding header,
+// and responses compressed with any of them are decompressed.
+// A gzip Compressor is registered by default.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	compressors[strings.ToLower(c.Name())] = c
+	compressorsMu.Unlock()
+}
+
+// GetCompressor returns the Compressor registered with the
+// name, or nil if there is none.
+func GetCompressor(name string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	return compressors[strings.ToLower(name)]
+}
+
+// UseCompressor compresses the request messages of the call with the
+// Compressor registered with the name. The call fails with Internal
+// if there is none. Transports for protocols without message
+// compression send the messages uncompressed.
+func UseCompressor(name string) CallOption {
+	return func(c *callInfo) {
+		c.compressor = name
+	}
+}
+
+// callCompressor returns the Compressor set on the call
+// with UseCompressor, or nil if the call is not compressed.
+func callCompressor(ci *callInfo) (Compressor, error) {
+	if ci.compressor == "" || ci.compressor == "identity" {
+		return nil, nil
+	}
+
+	c := GetCompressor(ci.compressor)
+	if c == nil {
+		return nil, &Error{Code: Internal, Message: "no compressor registered for " + encodingHeader + " " + ci.compressor}
+	}
+
+	return c, nil
+}
+
+// acceptEncoding returns the value of the grpc-accept-encoding
+// header, listing the names of all registered Compressors.
+func acceptEncoding() string {
+	compressorsMu.RLock()
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	compressorsMu.RUnlock()
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// compressionHeaders calls set with the compression
+// headers of a request to be compressed with c.
+func compressionHeaders(c Compressor, set func(k, v string)) {
+	if c != nil {
+		set(encodingHeader, c.Name())
+	}
+	set(acceptEncodingHeader, acceptEncoding())
+}
+
+// compress returns msg compressed with c.
+func compress(c Compressor, msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := c.Compress(&buf)
+	if err == nil {
+		if _, err = w.Write(msg); err == nil {
+			err = w.Close()
+		}
+	}
+	if err != nil {
+		return nil, &Error{Code: Internal, Message: "compressing message: " + err.Error(), Err: err}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompress returns msg decompressed with the Compressor named in
+// the grpc-encoding header. It fails with ResourceExhausted once the
+// decompressed message is larger than maxSize, without reading further.
+// A maxSize of 0 or less means no limit.
+func decompress(header Metadata, msg []byte, maxSize int) ([]byte, error) {
+	name := headerValue(header, encodingHeader)
+	if name == "" || name == "identity" {
+		return nil, &Error{Code: Internal, Message: "compressed message without " + encodingHeader}
+	}
+	c := GetCompressor(name)
+	if c == nil {
+		return nil, &Error{Code: Unimplemented, Message: "unsupported " + encodingHeader + " " + name}
+	}
+
+	r, err := c.Decompress(bytes.NewReader(msg))
+	if err == nil {
+		if maxSize > 0 {
+			r = io.LimitReader(r, int64(maxSize)+1)
+		}
+		msg, err = ioutil.ReadAll(r)
+	}
+	if err != nil {
+		return nil, &Error{Code: Internal, Message: "decompressing message: " + err.Error(), Err: err}
+	}
+	if maxSize > 0 && len(msg) > maxSize {
+		return nil, &Error{Code: ResourceExhausted, Message: "received message after decompression larger than max (" + strconv.Itoa(maxSize) + ")"}
+	}
+
+	return msg, nil
+}
+
+// gzipCompressor is the Compressor for gzip.
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCompressor) Name() string {
+	return "gzip"
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ClientConn is a client for the services at a target URL. Calls are
+// made with the method name, in the form "/package.Service/Method",
+// which is appended to the target. Interceptors and method retry
+// policies also receive the method name. It is the client
+// generated service clients are usually constructed with.
+type ClientConn struct {
+	clientBase
+}
+
+// Dial returns a ClientConn for the services at the target, a base URL
+// with an optional path prefix, such as "https://example.com/api".
+// An empty target or a target without a scheme and host is resolved
+// relative to the page origin. Dial accepts the same options as the other
+// clients. Unless configured with WithTransport, the ClientConn uses the
+// gRPC-Web protocol, with the same default Transport as GRPCWebClientBase.
+func Dial(target string, opts ...ClientOption) (*ClientConn, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https":
+		return nil, &Error{Code: InvalidArgument, Message: "unsupported target scheme " + u.Scheme}
+	case u.RawQuery != "" || u.Fragment != "":
+		return nil, &Error{Code: InvalidArgument, Message: "target must not have a query or fragment"}
+	}
+
+	cc := &ClientConn{
+		clientBase: newClientBase(defaultGRPCWebTransport(), opts),
+	}
+	cc.target = strings.TrimSuffix(target, "/")
+
+	return cc, nil
+}
+
+// Target returns the target of the ClientConn, without a trailing slash.
+func (cc *ClientConn) Target() string {
+	return cc.target
+}
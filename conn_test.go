@@ -0,0 +1,141 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+)
+
+func TestDial(t *testing.T) {
+	for _, tt := range []struct {
+		target     string
+		wantTarget string
+		wantErr    bool
+	}{
+		{"https://example.com", "https://example.com", false},
+		{"https://example.com/", "https://example.com", false},
+		{"http://localhost:8080/api/", "http://localhost:8080/api", false},
+		{"https://example.com/api", "https://example.com/api", false},
+		{"/api", "/api", false},
+		{"", "", false},
+		{"ws://example.com", "", true},
+		{"ftp://example.com", "", true},
+		{"https://example.com?key=value", "", true},
+		{"https://example.com/api#fragment", "", true},
+		{"https://example.com/%zz", "", true},
+	} {
+		t.Run(tt.target, func(t *testing.T) {
+			transport := grpcweb.NewFakeTransport()
+			transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+			cc, err := grpcweb.Dial(tt.target, grpcweb.WithTransport(transport))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Dial succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cc.Target(); got != tt.wantTarget {
+				t.Errorf("got target %q, want %q", got, tt.wantTarget)
+			}
+
+			// The method is appended to the target
+			if _, err := cc.RPCCall(method, message("req")); err != nil {
+				t.Fatal(err)
+			}
+			requests := transport.Requests()
+			if len(requests) != 1 || requests[0].Endpoint != tt.wantTarget+method {
+				t.Fatalf("got requests %+v, want one to %q", requests, tt.wantTarget+method)
+			}
+		})
+	}
+}
+
+func TestDialInvalidArgument(t *testing.T) {
+	for _, target := range []string{"ws://example.com", "https://example.com?key=value", "https://example.com#fragment"} {
+		if _, err := grpcweb.Dial(target); !errors.Is(err, grpcweb.ErrCode(grpcweb.InvalidArgument)) {
+			t.Errorf("Dial(%q) returned %v, want an InvalidArgument error", target, err)
+		}
+	}
+}
+
+func TestDialDefaultCallOptions(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+	cc, err := grpcweb.Dial("https://example.com/api",
+		grpcweb.WithTransport(transport),
+		grpcweb.WithDefaultCallOptions(
+			grpcweb.WithTimeout(time.Second),
+			grpcweb.WithMetadata(metadata.Pairs("x-default", "default", "x-both", "default")),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cc.RPCCall(method, message("req")); err != nil {
+		t.Fatal(err)
+	}
+	// Per-call options are applied after the default options
+	if _, err := cc.RPCCall(method, message("req"),
+		grpcweb.WithTimeout(2*time.Second),
+		grpcweb.WithMetadata(metadata.Pairs("x-both", "call")),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := transport.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	for i, tt := range []struct {
+		timeout time.Duration
+		both    []string
+	}{
+		{time.Second, []string{"default"}},
+		{2 * time.Second, []string{"default", "call"}},
+	} {
+		req := requests[i]
+		if req.Timeout != tt.timeout {
+			t.Errorf("request %d: got timeout %v, want %v", i, req.Timeout, tt.timeout)
+		}
+		if got := req.Header.Get("x-default"); len(got) != 1 || got[0] != "default" {
+			t.Errorf("request %d: got x-default %q, want the default metadata", i, got)
+		}
+		got := req.Header.Get("x-both")
+		if len(got) != len(tt.both) {
+			t.Errorf("request %d: got x-both %q, want %q", i, got, tt.both)
+			continue
+		}
+		for j := range got {
+			if got[j] != tt.both[j] {
+				t.Errorf("request %d: got x-both %q, want %q", i, got, tt.both)
+			}
+		}
+	}
+}
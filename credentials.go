@@ -0,0 +1,245 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+)
+
+// PerRPCCredentials supply the metadata, such as an authorization
+// header, attached to every call made by a client.
+type PerRPCCredentials interface {
+	// GetRequestMetadata returns the metadata to attach
+	// to a call to the method at uri.
+	GetRequestMetadata(uri string) (Metadata, error)
+	// RequireTransportSecurity reports whether the credentials
+	// must only be sent over HTTPS.
+	RequireTransportSecurity() bool
+	// Refresh is called with the metadata of a call that failed with
+	// Unauthenticated, before the call is retried. It should discard
+	// the metadata if it is still cached, so that the retried call
+	// gets fresh metadata from GetRequestMetadata.
+	Refresh(md Metadata)
+}
+
+// WithPerRPCCredentials sets the credentials used to add metadata to
+// every call. Unary and server streaming calls that fail with
+// Unauthenticated are retried once after refreshing the credentials.
+func WithPerRPCCredentials(creds PerRPCCredentials) ClientOption {
+	return func(c *clientBase) {
+		c.creds = creds
+	}
+}
+
+// requestHeader returns the headers of a call to the endpoint and
+// the metadata added to them by the credentials of the client.
+func (c *clientBase) requestHeader(endpoint string, ci *callInfo) (header Metadata, creds Metadata, err error) {
+	if c.creds == nil {
+		return ci.headers, nil, nil
+	}
+
+	if c.creds.RequireTransportSecurity() && !secureEndpoint(endpoint) {
+		return nil, nil, &Error{Code: Unauthenticated, Message: "credentials require transport security"}
+	}
+
+	creds, err = c.creds.GetRequestMetadata(endpoint)
+	if err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			err = &Error{Code: Unauthenticated, Message: "failed to get request metadata: " + err.Error(), Err: err}
+		}
+		return nil, nil, err
+	}
+
+	header = make(Metadata, len(ci.headers)+len(creds))
+	for k, v := range ci.headers {
+		header[k] = v
+	}
+	for k, v := range creds {
+		header[k] = v
+	}
+
+	return header, creds, nil
+}
+
+// refreshCredentials refreshes the credentials of the client if err
+// is an Unauthenticated error of a call made with the metadata creds.
+// It reports whether the call should be retried.
+func (c *clientBase) refreshCredentials(creds Metadata, err error) bool {
+	if c.creds == nil || creds == nil || !errors.Is(err, ErrCode(Unauthenticated)) {
+		return false
+	}
+
+	c.logger.Fine(logEntry("refreshing credentials", "error", err.Error()))
+	c.creds.Refresh(creds)
+	return true
+}
+
+// secureEndpoint reports whether calls to the endpoint are made over
+// HTTPS or a secure WebSocket, or to a loopback host. Endpoints without
+// a scheme are relative to a page origin that isn't known here, and
+// are not considered secure.
+func secureEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https", "wss":
+		return true
+	case "http", "ws":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		return false
+	}
+}
+
+// tokenExpiryDelta is how long before its expiry a token is refreshed,
+// so that it doesn't expire while a call is in flight.
+const tokenExpiryDelta = 10 * time.Second
+
+// Token is an access token sent in the authorization header of calls.
+type Token struct {
+	AccessToken string
+	// TokenType is the type of the token. It defaults to "Bearer".
+	TokenType string
+	// Expiry is when the token expires.
+	// The zero value means the token does not expire.
+	Expiry time.Time
+}
+
+func (t *Token) header() string {
+	if t.TokenType == "" {
+		return "Bearer " + t.AccessToken
+	}
+
+	return t.TokenType + " " + t.AccessToken
+}
+
+func (t *Token) valid() bool {
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.Expiry)
+}
+
+// TokenSource fetches a new Token.
+type TokenSource func() (*Token, error)
+
+// TokenCredentials are PerRPCCredentials sending a Token from a
+// TokenSource in the authorization header of calls. Tokens are cached
+// until shortly before they expire, and concurrent fetches of a new
+// token are coalesced into a single call to the TokenSource.
+// TokenCredentials require transport security, except for loopback
+// hosts, so they can only be used by clients with an absolute target.
+type TokenCredentials struct {
+	source TokenSource
+
+	mu       sync.Mutex
+	token    *Token
+	fetching *tokenFetch
+}
+
+// tokenFetch is a call to the TokenSource in progress.
+type tokenFetch struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// NewTokenCredentials returns TokenCredentials
+// using tokens fetched from source.
+func NewTokenCredentials(source TokenSource) *TokenCredentials {
+	return &TokenCredentials{source: source}
+}
+
+// GetRequestMetadata returns the authorization header of the cached
+// token, fetching a new token if it has expired.
+func (c *TokenCredentials) GetRequestMetadata(uri string) (Metadata, error) {
+	token, err := c.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.Pairs("authorization", token.header()), nil
+}
+
+// RequireTransportSecurity returns true.
+func (c *TokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// Refresh discards the cached token if it is the token in md.
+// Tokens fetched since md was returned are kept, so that calls failing
+// concurrently with the same token only cause a single fetch.
+func (c *TokenCredentials) Refresh(md Metadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != nil && headerValue(md, "authorization") == c.token.header() {
+		c.token = nil
+	}
+}
+
+// Token returns the cached token, fetching a new
+// token from the TokenSource if it has expired.
+func (c *TokenCredentials) Token() (*Token, error) {
+	c.mu.Lock()
+	if c.token != nil && c.token.valid() {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+
+	if f := c.fetching; f != nil {
+		c.mu.Unlock()
+		<-f.done
+		return f.token, f.err
+	}
+
+	f := &tokenFetch{done: make(chan struct{})}
+	c.fetching = f
+	c.mu.Unlock()
+
+	f.token, f.err = c.source()
+	if f.err == nil && f.token == nil {
+		f.err = &Error{Code: Unauthenticated, Message: "token source returned no token"}
+	}
+
+	c.mu.Lock()
+	c.fetching = nil
+	if f.err == nil {
+		c.token = f.token
+	}
+	c.mu.Unlock()
+	close(f.done)
+
+	return f.token, f.err
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+const target = "https://example.com"
+
+// tokenSource returns tokens "token-1", "token-2" and so on,
+// expiring after expiresIn, or never if expiresIn is 0.
+type tokenSource struct {
+	expiresIn time.Duration
+
+	mu      sync.Mutex
+	fetches int
+}
+
+func (s *tokenSource) token() (*grpcweb.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fetches++
+	token := &grpcweb.Token{AccessToken: "token-" + strconv.Itoa(s.fetches)}
+	if s.expiresIn != 0 {
+		token.Expiry = time.Now().Add(s.expiresIn)
+	}
+	return token, nil
+}
+
+func (s *tokenSource) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.fetches
+}
+
+// authorization returns the authorization header of the requests
+func authorization(requests []*grpcweb.TransportRequest) []string {
+	var auth []string
+	for _, req := range requests {
+		auth = append(auth, req.Header.Get("authorization")...)
+	}
+
+	return auth
+}
+
+func checkAuthorization(t *testing.T, requests []*grpcweb.TransportRequest, want ...string) {
+	t.Helper()
+
+	got := authorization(requests)
+	if len(got) != len(want) {
+		t.Fatalf("got authorization %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got authorization %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSecureEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		endpoint string
+		want     bool
+	}{
+		{"https://example.com/test.Service/Method", true},
+		{"wss://example.com/test.Service/Method", true},
+		{"http://localhost:8080/test.Service/Method", true},
+		{"ws://localhost/test.Service/Method", true},
+		{"http://127.0.0.1/test.Service/Method", true},
+		{"http://[::1]:8080/test.Service/Method", true},
+		{"http://example.com/test.Service/Method", false},
+		{"ws://example.com/test.Service/Method", false},
+		{"http://10.0.0.1/test.Service/Method", false},
+		{"ftp://example.com/test.Service/Method", false},
+		{"/test.Service/Method", false},
+		{"//example.com/test.Service/Method", false},
+		{"https://example.com/%zz", false},
+		{"http://[::1/test.Service/Method", false},
+	} {
+		if got := grpcweb.SecureEndpoint(tt.endpoint); got != tt.want {
+			t.Errorf("secureEndpoint(%q) = %t, want %t", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestTokenCredentialsTransportSecurity(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+	source := &tokenSource{}
+	creds := grpcweb.NewTokenCredentials(source.token)
+
+	for _, target := range []string{"http://example.com", ""} {
+		c, err := grpcweb.Dial(target, grpcweb.WithTransport(transport), grpcweb.WithPerRPCCredentials(creds))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.RPCCall(method, message("req")); !errors.Is(err, grpcweb.ErrCode(grpcweb.Unauthenticated)) {
+			t.Errorf("target %q: got %v, want an Unauthenticated error", target, err)
+		}
+	}
+	if n := len(transport.Requests()); n != 0 {
+		t.Errorf("got %d requests, want none", n)
+	}
+	if n := source.count(); n != 0 {
+		t.Errorf("got %d token fetches, want none", n)
+	}
+}
+
+func TestTokenCredentialsCache(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		expiresIn time.Duration
+		want      []string
+	}{
+		{"no expiry", 0, []string{"Bearer token-1", "Bearer token-1", "Bearer token-1"}},
+		{"valid", time.Hour, []string{"Bearer token-1", "Bearer token-1", "Bearer token-1"}},
+		// Tokens are refreshed shortly before they expire
+		{"expiring", 5 * time.Second, []string{"Bearer token-1", "Bearer token-2", "Bearer token-3"}},
+		{"expired", -time.Second, []string{"Bearer token-1", "Bearer token-2", "Bearer token-3"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := grpcweb.NewTokenCredentials((&tokenSource{expiresIn: tt.expiresIn}).token)
+			var got []string
+			for range tt.want {
+				md, err := creds.GetRequestMetadata(target + method)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, md.Get("authorization")...)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got authorization %q, want %q", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got authorization %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenCredentialsTokenType(t *testing.T) {
+	creds := grpcweb.NewTokenCredentials(func() (*grpcweb.Token, error) {
+		return &grpcweb.Token{AccessToken: "secret", TokenType: "MAC"}, nil
+	})
+	md, err := creds.GetRequestMetadata(target + method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "MAC secret" {
+		t.Errorf("got authorization %q, want %q", got, "MAC secret")
+	}
+}
+
+func TestTokenCredentialsSourceError(t *testing.T) {
+	errSource := errors.New("no network")
+	transport := grpcweb.NewFakeTransport()
+	c, err := grpcweb.Dial(target,
+		grpcweb.WithTransport(transport),
+		grpcweb.WithPerRPCCredentials(grpcweb.NewTokenCredentials(func() (*grpcweb.Token, error) {
+			return nil, errSource
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.RPCCall(method, message("req"))
+	if !errors.Is(err, grpcweb.ErrCode(grpcweb.Unauthenticated)) || !errors.Is(err, errSource) {
+		t.Errorf("got %v, want an Unauthenticated error wrapping the error of the source", err)
+	}
+	if n := len(transport.Requests()); n != 0 {
+		t.Errorf("got %d requests, want none", n)
+	}
+}
+
+func TestTokenCredentialsConcurrentFetch(t *testing.T) {
+	release := make(chan struct{})
+	var fetches int
+	var mu sync.Mutex
+	creds := grpcweb.NewTokenCredentials(func() (*grpcweb.Token, error) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		<-release
+		return &grpcweb.Token{AccessToken: "shared"}, nil
+	})
+
+	const callers = 10
+	tokens := make(chan *grpcweb.Token, callers)
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			token, err := creds.Token()
+			tokens <- token
+			errs <- err
+		}()
+	}
+	close(release)
+
+	for i := 0; i < callers; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if token := <-tokens; token.AccessToken != "shared" {
+			t.Errorf("got token %q, want %q", token.AccessToken, "shared")
+		}
+	}
+	// Callers arriving during the fetch wait for it,
+	// and later callers get the cached token
+	if fetches != 1 {
+		t.Errorf("got %d fetches, want 1", fetches)
+	}
+}
+
+func TestTokenCredentialsRefresh(t *testing.T) {
+	source := &tokenSource{}
+	creds := grpcweb.NewTokenCredentials(source.token)
+	md, err := creds.GetRequestMetadata(target + method)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds.Refresh(md)
+	md2, err := creds.GetRequestMetadata(target + method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Refreshing with a token that has already been
+	// replaced keeps the new token
+	creds.Refresh(md)
+	md3, err := creds.GetRequestMetadata(target + method)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{md.Get("authorization")[0], md2.Get("authorization")[0], md3.Get("authorization")[0]}
+	if got[0] != "Bearer token-1" || got[1] != "Bearer token-2" || got[2] != "Bearer token-2" {
+		t.Errorf("got authorization %q, want token-1 followed by token-2 twice", got)
+	}
+}
+
+func TestCredentialsRefreshRetry(t *testing.T) {
+	unauthenticated := &grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unauthenticated, Message: "token expired"}}
+	ok := &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}}
+	for _, tt := range []struct {
+		name      string
+		responses []*grpcweb.FakeResponse
+		wantErr   bool
+	}{
+		{"refreshed", []*grpcweb.FakeResponse{unauthenticated, ok}, false},
+		// Only a single refresh is attempted
+		{"still unauthenticated", []*grpcweb.FakeResponse{unauthenticated, unauthenticated, ok}, true},
+	} {
+		for _, call := range []struct {
+			name string
+			recv func(c *grpcweb.ClientConn) error
+		}{
+			{"unary", func(c *grpcweb.ClientConn) error {
+				_, err := c.RPCCall(method, message("req"))
+				return err
+			}},
+			{"server stream", func(c *grpcweb.ClientConn) error {
+				stream, err := c.ServerStreaming(method, message("req"))
+				if err != nil {
+					return err
+				}
+				defer stream.Close()
+				_, err = stream.Recv()
+				return err
+			}},
+		} {
+			t.Run(tt.name+"/"+call.name, func(t *testing.T) {
+				transport := grpcweb.NewFakeTransport()
+				transport.Script(method, tt.responses...)
+				source := &tokenSource{}
+				c, err := grpcweb.Dial(target,
+					grpcweb.WithTransport(transport),
+					grpcweb.WithPerRPCCredentials(grpcweb.NewTokenCredentials(source.token)),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = call.recv(c)
+				if !tt.wantErr && err != nil {
+					t.Fatal(err)
+				}
+				if tt.wantErr && !errors.Is(err, grpcweb.ErrCode(grpcweb.Unauthenticated)) {
+					t.Fatalf("got %v, want an Unauthenticated error", err)
+				}
+				checkAuthorization(t, transport.Requests(), "Bearer token-1", "Bearer token-2")
+			})
+		}
+	}
+}
+
+func TestCredentialsRefreshRetryPolicy(t *testing.T) {
+	// The refreshed attempt doesn't count against the retry policy
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method,
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unauthenticated}},
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unavailable}},
+		&grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}},
+	)
+	source := &tokenSource{}
+	c, err := grpcweb.Dial(target,
+		grpcweb.WithTransport(transport),
+		grpcweb.WithPerRPCCredentials(grpcweb.NewTokenCredentials(source.token)),
+		grpcweb.WithRetryPolicy(&grpcweb.RetryPolicy{
+			MaxAttempts:          2,
+			InitialBackoff:       time.Millisecond,
+			RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.RPCCall(method, message("req")); err != nil {
+		t.Fatal(err)
+	}
+	checkAuthorization(t, transport.Requests(), "Bearer token-1", "Bearer token-2", "Bearer token-2")
+}
@@ -0,0 +1,762 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package errdetails provides the messages of google/rpc/error_details.proto,
+// the standard details of a google.rpc.Status, for use with Status.Details.
+// The messages are plain Go structs, which build with both GopherJS and
+// the standard Go compiler.
+package errdetails
+
+import (
+	"errors"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+// typeURLPrefix is the prefix of the type URLs of Any messages.
+const typeURLPrefix = "type.googleapis.com/"
+
+// Message is implemented by all the messages of the package.
+type Message interface {
+	grpcweb.ProtoMessage
+	// MessageName returns the full name of the message type.
+	MessageName() string
+}
+
+// ErrUnknownType is returned by Decode for an Any
+// holding a message that is not of a type of the package.
+var ErrUnknownType = errors.New("errdetails: unknown message type")
+
+// Decode returns the message held by the Any, as a value of the
+// matching type of the package, such as *BadRequest.
+func Decode(a *grpcweb.Any) (Message, error) {
+	var m Message
+	switch a.MessageName() {
+	case "google.rpc.ErrorInfo":
+		m = &ErrorInfo{}
+	case "google.rpc.RetryInfo":
+		m = &RetryInfo{}
+	case "google.rpc.DebugInfo":
+		m = &DebugInfo{}
+	case "google.rpc.QuotaFailure":
+		m = &QuotaFailure{}
+	case "google.rpc.PreconditionFailure":
+		m = &PreconditionFailure{}
+	case "google.rpc.BadRequest":
+		m = &BadRequest{}
+	case "google.rpc.RequestInfo":
+		m = &RequestInfo{}
+	case "google.rpc.ResourceInfo":
+		m = &ResourceInfo{}
+	case "google.rpc.Help":
+		m = &Help{}
+	case "google.rpc.LocalizedMessage":
+		m = &LocalizedMessage{}
+	default:
+		return nil, ErrUnknownType
+	}
+
+	if err := a.UnmarshalTo(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// DecodeAll returns the messages of the details that are
+// of a type of the package, skipping any other details.
+func DecodeAll(details []grpcweb.Any) ([]Message, error) {
+	var out []Message
+	for i := range details {
+		m, err := Decode(&details[i])
+		if err == ErrUnknownType {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
+// NewAny returns an Any holding m.
+func NewAny(m Message) (grpcweb.Any, error) {
+	v, err := m.Serialize()
+	if err != nil {
+		return grpcweb.Any{}, err
+	}
+
+	return grpcweb.Any{TypeURL: typeURLPrefix + m.MessageName(), Value: v}, nil
+}
+
+// FieldViolations returns the field violations of the BadRequest
+// details of err, keyed by field. It returns nil if err does not
+// wrap a *grpcweb.Error or has no BadRequest details.
+func FieldViolations(err error) map[string][]*BadRequest_FieldViolation {
+	var e *grpcweb.Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	var violations map[string][]*BadRequest_FieldViolation
+	for i := range e.Details {
+		if e.Details[i].MessageName() != "google.rpc.BadRequest" {
+			continue
+		}
+		br := &BadRequest{}
+		if e.Details[i].UnmarshalTo(br) != nil {
+			continue
+		}
+		for _, v := range br.FieldViolations {
+			if violations == nil {
+				violations = map[string][]*BadRequest_FieldViolation{}
+			}
+			violations[v.Field] = append(violations[v.Field], v)
+		}
+	}
+
+	return violations
+}
+
+// ErrorInfo describes the cause of an error with structured details.
+type ErrorInfo struct {
+	Reason   string
+	Domain   string
+	Metadata map[string]string
+}
+
+// MessageName returns "google.rpc.ErrorInfo".
+func (m *ErrorInfo) MessageName() string { return "google.rpc.ErrorInfo" }
+
+// Serialize marshals ErrorInfo to the protobuf wire format.
+func (m *ErrorInfo) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.Reason; v != "" {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := m.Domain; v != "" {
+		b.EncodeTag(2, wire.Bytes)
+		b.EncodeString(v)
+	}
+	for k, v := range m.Metadata {
+		b.EncodeTag(3, wire.Bytes)
+		b.EncodeBytes(encodeMapEntry(k, v))
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals ErrorInfo from the protobuf wire format.
+func (m *ErrorInfo) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			m.Reason, err = b.DecodeString()
+		case 2:
+			m.Domain, err = b.DecodeString()
+		case 3:
+			var v []byte
+			v, err = b.DecodeBytes()
+			if err == nil {
+				if m.Metadata == nil {
+					m.Metadata = map[string]string{}
+				}
+				err = decodeMapEntry(v, m.Metadata)
+			}
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeMapEntry encodes an entry of a map<string, string> field.
+func encodeMapEntry(k, v string) []byte {
+	b := wire.NewBuffer(nil)
+	b.EncodeTag(1, wire.Bytes)
+	b.EncodeString(k)
+	b.EncodeTag(2, wire.Bytes)
+	b.EncodeString(v)
+
+	return b.Bytes()
+}
+
+// decodeMapEntry decodes an entry of a map<string, string> field into m.
+func decodeMapEntry(data []byte, m map[string]string) error {
+	var k, v string
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			k, err = b.DecodeString()
+		case 2:
+			v, err = b.DecodeString()
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	m[k] = v
+
+	return nil
+}
+
+// RetryInfo tells the client how long to wait before retrying the call.
+type RetryInfo struct {
+	// RetryDelay is the google.protobuf.Duration retry_delay.
+	RetryDelay time.Duration
+}
+
+// MessageName returns "google.rpc.RetryInfo".
+func (m *RetryInfo) MessageName() string { return "google.rpc.RetryInfo" }
+
+// Serialize marshals RetryInfo to the protobuf wire format.
+func (m *RetryInfo) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.RetryDelay; v != 0 {
+		d := wire.NewBuffer(nil)
+		if s := int64(v / time.Second); s != 0 {
+			d.EncodeTag(1, wire.Varint)
+			d.EncodeVarint(uint64(s))
+		}
+		if n := int32(v % time.Second); n != 0 {
+			d.EncodeTag(2, wire.Varint)
+			d.EncodeVarint(uint64(n))
+		}
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeBytes(d.Bytes())
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals RetryInfo from the protobuf wire format.
+func (m *RetryInfo) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			var v []byte
+			v, err = b.DecodeBytes()
+			if err == nil {
+				m.RetryDelay, err = decodeDuration(v)
+			}
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeDuration decodes a google.protobuf.Duration.
+func decodeDuration(data []byte) (time.Duration, error) {
+	var seconds, nanos int64
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return 0, err
+		}
+
+		var v uint64
+		switch field {
+		case 1:
+			v, err = b.DecodeVarint()
+			seconds = int64(v)
+		case 2:
+			v, err = b.DecodeVarint()
+			nanos = int64(int32(v))
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Duration(seconds)*time.Second + time.Duration(nanos), nil
+}
+
+// DebugInfo describes additional debugging info.
+type DebugInfo struct {
+	StackEntries []string
+	Detail       string
+}
+
+// MessageName returns "google.rpc.DebugInfo".
+func (m *DebugInfo) MessageName() string { return "google.rpc.DebugInfo" }
+
+// Serialize marshals DebugInfo to the protobuf wire format.
+func (m *DebugInfo) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	for _, v := range m.StackEntries {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := m.Detail; v != "" {
+		b.EncodeTag(2, wire.Bytes)
+		b.EncodeString(v)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals DebugInfo from the protobuf wire format.
+func (m *DebugInfo) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			var v string
+			v, err = b.DecodeString()
+			m.StackEntries = append(m.StackEntries, v)
+		case 2:
+			m.Detail, err = b.DecodeString()
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QuotaFailure describes how a quota check failed.
+type QuotaFailure struct {
+	Violations []*QuotaFailure_Violation
+}
+
+// MessageName returns "google.rpc.QuotaFailure".
+func (m *QuotaFailure) MessageName() string { return "google.rpc.QuotaFailure" }
+
+// Serialize marshals QuotaFailure to the protobuf wire format.
+func (m *QuotaFailure) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	for _, v := range m.Violations {
+		b.EncodeTag(1, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals QuotaFailure from the protobuf wire format.
+func (m *QuotaFailure) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			v := &QuotaFailure_Violation{}
+			err = b.DecodeMessage(v)
+			m.Violations = append(m.Violations, v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QuotaFailure_Violation describes a single quota violation.
+type QuotaFailure_Violation struct {
+	Subject     string
+	Description string
+}
+
+// Serialize marshals QuotaFailure_Violation to the protobuf wire format.
+func (m *QuotaFailure_Violation) Serialize() ([]byte, error) {
+	return encodeStrings(m.Subject, m.Description), nil
+}
+
+// Deserialize unmarshals QuotaFailure_Violation from the protobuf wire format.
+func (m *QuotaFailure_Violation) Deserialize(data []byte) error {
+	return decodeStrings(data, &m.Subject, &m.Description)
+}
+
+// PreconditionFailure describes what preconditions have failed.
+type PreconditionFailure struct {
+	Violations []*PreconditionFailure_Violation
+}
+
+// MessageName returns "google.rpc.PreconditionFailure".
+func (m *PreconditionFailure) MessageName() string { return "google.rpc.PreconditionFailure" }
+
+// Serialize marshals PreconditionFailure to the protobuf wire format.
+func (m *PreconditionFailure) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	for _, v := range m.Violations {
+		b.EncodeTag(1, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals PreconditionFailure from the protobuf wire format.
+func (m *PreconditionFailure) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			v := &PreconditionFailure_Violation{}
+			err = b.DecodeMessage(v)
+			m.Violations = append(m.Violations, v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PreconditionFailure_Violation describes a single precondition failure.
+type PreconditionFailure_Violation struct {
+	Type        string
+	Subject     string
+	Description string
+}
+
+// Serialize marshals PreconditionFailure_Violation to the protobuf wire format.
+func (m *PreconditionFailure_Violation) Serialize() ([]byte, error) {
+	return encodeStrings(m.Type, m.Subject, m.Description), nil
+}
+
+// Deserialize unmarshals PreconditionFailure_Violation from the protobuf wire format.
+func (m *PreconditionFailure_Violation) Deserialize(data []byte) error {
+	return decodeStrings(data, &m.Type, &m.Subject, &m.Description)
+}
+
+// BadRequest describes violations in a client request,
+// such as the invalid fields of a form.
+type BadRequest struct {
+	FieldViolations []*BadRequest_FieldViolation
+}
+
+// MessageName returns "google.rpc.BadRequest".
+func (m *BadRequest) MessageName() string { return "google.rpc.BadRequest" }
+
+// Serialize marshals BadRequest to the protobuf wire format.
+func (m *BadRequest) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	for _, v := range m.FieldViolations {
+		b.EncodeTag(1, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals BadRequest from the protobuf wire format.
+func (m *BadRequest) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			v := &BadRequest_FieldViolation{}
+			err = b.DecodeMessage(v)
+			m.FieldViolations = append(m.FieldViolations, v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BadRequest_FieldViolation describes a single bad request field.
+// Field is a path to the field, such as "address.street".
+type BadRequest_FieldViolation struct {
+	Field            string
+	Description      string
+	Reason           string
+	LocalizedMessage *LocalizedMessage
+}
+
+// Serialize marshals BadRequest_FieldViolation to the protobuf wire format.
+func (m *BadRequest_FieldViolation) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(encodeStrings(m.Field, m.Description, m.Reason))
+	if v := m.LocalizedMessage; v != nil {
+		b.EncodeTag(4, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals BadRequest_FieldViolation from the protobuf wire format.
+func (m *BadRequest_FieldViolation) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			m.Field, err = b.DecodeString()
+		case 2:
+			m.Description, err = b.DecodeString()
+		case 3:
+			m.Reason, err = b.DecodeString()
+		case 4:
+			v := &LocalizedMessage{}
+			err = b.DecodeMessage(v)
+			m.LocalizedMessage = v
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RequestInfo contains metadata about the request
+// that clients can attach when filing a bug.
+type RequestInfo struct {
+	RequestId   string
+	ServingData string
+}
+
+// MessageName returns "google.rpc.RequestInfo".
+func (m *RequestInfo) MessageName() string { return "google.rpc.RequestInfo" }
+
+// Serialize marshals RequestInfo to the protobuf wire format.
+func (m *RequestInfo) Serialize() ([]byte, error) {
+	return encodeStrings(m.RequestId, m.ServingData), nil
+}
+
+// Deserialize unmarshals RequestInfo from the protobuf wire format.
+func (m *RequestInfo) Deserialize(data []byte) error {
+	return decodeStrings(data, &m.RequestId, &m.ServingData)
+}
+
+// ResourceInfo describes the resource that is being accessed.
+type ResourceInfo struct {
+	ResourceType string
+	ResourceName string
+	Owner        string
+	Description  string
+}
+
+// MessageName returns "google.rpc.ResourceInfo".
+func (m *ResourceInfo) MessageName() string { return "google.rpc.ResourceInfo" }
+
+// Serialize marshals ResourceInfo to the protobuf wire format.
+func (m *ResourceInfo) Serialize() ([]byte, error) {
+	return encodeStrings(m.ResourceType, m.ResourceName, m.Owner, m.Description), nil
+}
+
+// Deserialize unmarshals ResourceInfo from the protobuf wire format.
+func (m *ResourceInfo) Deserialize(data []byte) error {
+	return decodeStrings(data, &m.ResourceType, &m.ResourceName, &m.Owner, &m.Description)
+}
+
+// Help provides links to documentation or for performing an out of band action.
+type Help struct {
+	Links []*Help_Link
+}
+
+// MessageName returns "google.rpc.Help".
+func (m *Help) MessageName() string { return "google.rpc.Help" }
+
+// Serialize marshals Help to the protobuf wire format.
+func (m *Help) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	for _, v := range m.Links {
+		b.EncodeTag(1, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals Help from the protobuf wire format.
+func (m *Help) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			v := &Help_Link{}
+			err = b.DecodeMessage(v)
+			m.Links = append(m.Links, v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Help_Link describes a URL link.
+type Help_Link struct {
+	Description string
+	Url         string
+}
+
+// Serialize marshals Help_Link to the protobuf wire format.
+func (m *Help_Link) Serialize() ([]byte, error) {
+	return encodeStrings(m.Description, m.Url), nil
+}
+
+// Deserialize unmarshals Help_Link from the protobuf wire format.
+func (m *Help_Link) Deserialize(data []byte) error {
+	return decodeStrings(data, &m.Description, &m.Url)
+}
+
+// LocalizedMessage is an error message localized for the user.
+// Locale is a BCP 47 language tag, such as "en-US".
+type LocalizedMessage struct {
+	Locale  string
+	Message string
+}
+
+// MessageName returns "google.rpc.LocalizedMessage".
+func (m *LocalizedMessage) MessageName() string { return "google.rpc.LocalizedMessage" }
+
+// Serialize marshals LocalizedMessage to the protobuf wire format.
+func (m *LocalizedMessage) Serialize() ([]byte, error) {
+	return encodeStrings(m.Locale, m.Message), nil
+}
+
+// Deserialize unmarshals LocalizedMessage from the protobuf wire format.
+func (m *LocalizedMessage) Deserialize(data []byte) error {
+	return decodeStrings(data, &m.Locale, &m.Message)
+}
+
+// encodeStrings encodes a message whose fields are all strings,
+// numbered from 1 in the order of fields.
+func encodeStrings(fields ...string) []byte {
+	b := wire.NewBuffer(nil)
+	for i, v := range fields {
+		if v != "" {
+			b.EncodeTag(int32(i+1), wire.Bytes)
+			b.EncodeString(v)
+		}
+	}
+
+	return b.Bytes()
+}
+
+// decodeStrings decodes a message whose fields are all strings,
+// numbered from 1 in the order of fields. Other fields are skipped.
+func decodeStrings(data []byte, fields ...*string) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		if field < 1 || int(field) > len(fields) || typ != wire.Bytes {
+			err = b.Skip(field, typ)
+		} else {
+			*fields[field-1], err = b.DecodeString()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package errdetails_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	genproto "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/errdetails"
+)
+
+// fixture returns an Any holding m, encoded by the protobuf runtime
+func fixture(t *testing.T, m proto.Message) grpcweb.Any {
+	t.Helper()
+
+	v, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return grpcweb.Any{TypeURL: "type.googleapis.com/" + string(m.ProtoReflect().Descriptor().FullName()), Value: v}
+}
+
+func TestDecodeBadRequest(t *testing.T) {
+	a := fixture(t, &genproto.BadRequest{
+		FieldViolations: []*genproto.BadRequest_FieldViolation{
+			{Field: "name", Description: "must not be empty"},
+			{Field: "email", Description: "is not an email address"},
+			{Field: "name", Description: "is too short"},
+		},
+	})
+
+	m, err := errdetails.Decode(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br, ok := m.(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("got %T, want *BadRequest", m)
+	}
+	if len(br.FieldViolations) != 3 {
+		t.Fatalf("got %d field violations, want 3", len(br.FieldViolations))
+	}
+	if v := br.FieldViolations[1]; v.Field != "email" || v.Description != "is not an email address" {
+		t.Errorf("got second violation %+v, want the email violation", v)
+	}
+
+	violations := errdetails.FieldViolations(&grpcweb.Error{Code: grpcweb.InvalidArgument, Details: []grpcweb.Any{a}})
+	if len(violations["name"]) != 2 || len(violations["email"]) != 1 {
+		t.Errorf("got violations %v, want 2 for name and 1 for email", violations)
+	}
+	if got := violations["name"][1].Description; got != "is too short" {
+		t.Errorf("got second name violation %q, want %q", got, "is too short")
+	}
+}
+
+func TestDecodeRetryInfo(t *testing.T) {
+	for _, delay := range []time.Duration{0, 1500 * time.Millisecond, 90 * time.Second, -time.Second} {
+		a := fixture(t, &genproto.RetryInfo{RetryDelay: durationpb.New(delay)})
+
+		m, err := errdetails.Decode(&a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ri, ok := m.(*errdetails.RetryInfo)
+		if !ok {
+			t.Fatalf("got %T, want *RetryInfo", m)
+		}
+		if ri.RetryDelay != delay {
+			t.Errorf("got delay %v, want %v", ri.RetryDelay, delay)
+		}
+	}
+}
+
+func TestDecodeLocalizedMessage(t *testing.T) {
+	a := fixture(t, &genproto.LocalizedMessage{Locale: "sv-SE", Message: "Namnet får inte vara tomt"})
+
+	m, err := errdetails.Decode(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, ok := m.(*errdetails.LocalizedMessage)
+	if !ok {
+		t.Fatalf("got %T, want *LocalizedMessage", m)
+	}
+	if lm.Locale != "sv-SE" || lm.Message != "Namnet får inte vara tomt" {
+		t.Errorf("got %+v, want the Swedish message", lm)
+	}
+}
+
+func TestDecodeErrorInfo(t *testing.T) {
+	a := fixture(t, &genproto.ErrorInfo{
+		Reason:   "API_DISABLED",
+		Domain:   "example.com",
+		Metadata: map[string]string{"service": "users", "consumer": "projects/1"},
+	})
+
+	m, err := errdetails.Decode(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ei, ok := m.(*errdetails.ErrorInfo)
+	if !ok {
+		t.Fatalf("got %T, want *ErrorInfo", m)
+	}
+	if ei.Reason != "API_DISABLED" || ei.Domain != "example.com" || len(ei.Metadata) != 2 ||
+		ei.Metadata["service"] != "users" || ei.Metadata["consumer"] != "projects/1" {
+		t.Errorf("got %+v, want the encoded ErrorInfo", ei)
+	}
+}
+
+func TestNewAny(t *testing.T) {
+	// Messages encoded by the package are decoded by the protobuf runtime
+	a, err := errdetails.NewAny(&errdetails.RetryInfo{RetryDelay: 2500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.TypeURL != "type.googleapis.com/google.rpc.RetryInfo" {
+		t.Errorf("got type URL %q", a.TypeURL)
+	}
+	var ri genproto.RetryInfo
+	if err := proto.Unmarshal(a.Value, &ri); err != nil {
+		t.Fatal(err)
+	}
+	if got := ri.GetRetryDelay().AsDuration(); got != 2500*time.Millisecond {
+		t.Errorf("got delay %v, want %v", got, 2500*time.Millisecond)
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	details := []grpcweb.Any{
+		fixture(t, &genproto.LocalizedMessage{Locale: "en-US", Message: "try again"}),
+		fixture(t, wrapperspb.String("not a detail of the package")),
+		{TypeURL: "type.googleapis.com/example.Unknown", Value: []byte{0xff, 0xff}},
+		fixture(t, &genproto.RetryInfo{RetryDelay: durationpb.New(time.Second)}),
+	}
+
+	msgs, err := errdetails.DecodeAll(details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want the 2 of known types", len(msgs))
+	}
+	if _, ok := msgs[0].(*errdetails.LocalizedMessage); !ok {
+		t.Errorf("got %T first, want *LocalizedMessage", msgs[0])
+	}
+	if _, ok := msgs[1].(*errdetails.RetryInfo); !ok {
+		t.Errorf("got %T second, want *RetryInfo", msgs[1])
+	}
+
+	if _, err := errdetails.Decode(&details[1]); !errors.Is(err, errdetails.ErrUnknownType) {
+		t.Errorf("Decode of an unknown type returned %v, want ErrUnknownType", err)
+	}
+
+	// Malformed details of known types are errors
+	details = append(details, grpcweb.Any{TypeURL: "type.googleapis.com/google.rpc.BadRequest", Value: []byte{0x0a, 0x05}})
+	if _, err := errdetails.DecodeAll(details); err == nil {
+		t.Error("DecodeAll succeeded with a truncated BadRequest")
+	}
+}
@@ -20,20 +20,47 @@
 
 package grpcweb
 
-import (
-	"github.com/gopherjs/gopherjs/js"
-)
+import "io"
 
 // Error is a gRPC-web Error
 type Error struct {
-	*js.Object
-	Code    StatusCode `js:"code"`
-	Message string     `js:"message"`
+	Code    StatusCode
+	Message string
+	// Details are the details of the status of the call.
+	Details []Any
+	// Err is the cause of the error, such as the JS error or
+	// transport error that failed the call, if there is one.
+	Err error
 }
 
 func (e *Error) Error() string {
-	return "Error code: " + string(e.Code) + `, "` + e.Message + `"`
+	return "Error code: " + e.Code.String() + `, "` + e.Message + `"`
 }
 
-// EOF is sent when a streaming request is finished
-var EOF = &Error{Code: Ok, Message: "EOF"}
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code,
+// and the same message unless the message of target is empty.
+// It makes errors.Is(err, ErrCode(NotFound)) report whether
+// err is a NotFound error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return t.Code == e.Code && (t.Message == "" || t.Message == e.Message)
+}
+
+// ErrCode returns an error matching all errors with the code,
+// for use with errors.Is.
+func ErrCode(code StatusCode) error {
+	return &Error{Code: code}
+}
+
+// EOF is returned by Recv when a stream has finished successfully.
+// It is io.EOF, so that it can be compared with either.
+var EOF = io.EOF
@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	err := &Error{Code: NotFound, Message: "no such thing"}
+	for _, tt := range []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"code only", err, ErrCode(NotFound), true},
+		{"code and message", err, &Error{Code: NotFound, Message: "no such thing"}, true},
+		{"other message", err, &Error{Code: NotFound, Message: "something else"}, false},
+		{"other code", err, ErrCode(Internal), false},
+		{"other code and message", err, &Error{Code: Internal, Message: "no such thing"}, false},
+		{"target with a message", ErrCode(NotFound), &Error{Code: NotFound, Message: "no such thing"}, false},
+		{"not an Error", err, io.EOF, false},
+		{"wrapped", fmt.Errorf("calling: %w", err), ErrCode(NotFound), true},
+		{"wrapped other code", fmt.Errorf("calling: %w", err), ErrCode(Internal), false},
+	} {
+		if got := errors.Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("%s: errors.Is(%v, %v) = %v, want %v", tt.name, tt.err, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := error(&Error{Code: Unavailable, Message: "transport failed", Err: cause})
+
+	if got := errors.Unwrap(err); got != cause {
+		t.Errorf("Unwrap() = %v, want %v", got, cause)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false")
+	}
+	if !errors.Is(err, ErrCode(Unavailable)) {
+		t.Error("errors.Is(err, ErrCode(Unavailable)) = false")
+	}
+
+	var e *Error
+	if !errors.As(fmt.Errorf("calling: %w", err), &e) || e.Code != Unavailable {
+		t.Errorf("errors.As returned %v, want the Unavailable error", e)
+	}
+
+	if got := errors.Unwrap(&Error{Code: Internal}); got != nil {
+		t.Errorf("Unwrap() of an error without a cause = %v, want nil", got)
+	}
+}
+
+func TestEOF(t *testing.T) {
+	if EOF != io.EOF {
+		t.Error("EOF is not io.EOF")
+	}
+	if errors.Is(EOF, ErrCode(Ok)) {
+		t.Error("EOF matches ErrCode(Ok)")
+	}
+}
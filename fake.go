@@ -0,0 +1,213 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"strings"
+	"sync"
+)
+
+// FakeResponse is a response replayed by a FakeTransport.
+type FakeResponse struct {
+	// Header holds the response headers.
+	Header Metadata
+	// Messages are the response messages, sent in order.
+	Messages []ProtoMessage
+	// Status is the status sent after the messages.
+	// A nil Status is sent as Ok.
+	Status *Status
+	// Err, if set, fails the response with Err after the
+	// messages have been received, instead of sending a status.
+	Err error
+}
+
+// FakeTransport is a StreamingTransport replaying scripted
+// responses, for testing code using clients without a server.
+// Requests to endpoints without a scripted response fail
+// with Unimplemented.
+type FakeTransport struct {
+	mu        sync.Mutex
+	responses map[string][]*FakeResponse
+	requests  []*TransportRequest
+	sent      map[string][][]byte
+}
+
+// NewFakeTransport returns a new FakeTransport
+// without any scripted responses.
+func NewFakeTransport() *FakeTransport {
+	return &FakeTransport{
+		responses: map[string][]*FakeResponse{},
+		sent:      map[string][][]byte{},
+	}
+}
+
+// Script adds responses for requests to endpoints ending with
+// method, such as "/package.Service/Method". The responses are
+// replayed in order, one per request, after which the last
+// response is replayed for all further requests.
+func (t *FakeTransport) Script(method string, responses ...*FakeResponse) {
+	t.mu.Lock()
+	t.responses[method] = append(t.responses[method], responses...)
+	t.mu.Unlock()
+}
+
+// Requests returns the requests received so far.
+func (t *FakeTransport) Requests() []*TransportRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*TransportRequest(nil), t.requests...)
+}
+
+// Sent returns the serialized messages sent on streams
+// to endpoints ending with method.
+func (t *FakeTransport) Sent(method string) [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sent [][]byte
+	for endpoint, msgs := range t.sent {
+		if strings.HasSuffix(endpoint, method) {
+			sent = append(sent, msgs...)
+		}
+	}
+
+	return sent
+}
+
+// NewStream implements Transport.
+func (t *FakeTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	return t.newStream(req)
+}
+
+// NewDuplexStream implements StreamingTransport.
+func (t *FakeTransport) NewDuplexStream(req *TransportRequest) (DuplexTransportStream, error) {
+	return t.newStream(req)
+}
+
+func (t *FakeTransport) newStream(req *TransportRequest) (*fakeStream, error) {
+	t.mu.Lock()
+	t.requests = append(t.requests, req)
+	resp := t.next(req.Endpoint)
+	t.mu.Unlock()
+
+	s := &fakeStream{
+		transport: t,
+		endpoint:  req.Endpoint,
+		header:    Metadata{},
+	}
+	if resp == nil {
+		s.frames = []*Frame{{Status: &Status{Code: Unimplemented, Message: "no response scripted for " + req.Endpoint}}}
+		return s, nil
+	}
+
+	for k, v := range resp.Header {
+		s.header.Append(k, v...)
+	}
+	for _, msg := range resp.Messages {
+		b, err := msg.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		s.frames = append(s.frames, &Frame{Message: b})
+	}
+	switch {
+	case resp.Err != nil:
+		s.err = resp.Err
+	case resp.Status != nil:
+		s.frames = append(s.frames, &Frame{Status: resp.Status})
+	default:
+		s.frames = append(s.frames, &Frame{Status: &Status{Code: Ok}})
+	}
+
+	return s, nil
+}
+
+// next returns the next response scripted for the endpoint. If several
+// methods match, the longest one is used. It must be called with t.mu held.
+func (t *FakeTransport) next(endpoint string) *FakeResponse {
+	match := ""
+	for method, responses := range t.responses {
+		if strings.HasSuffix(endpoint, method) && len(responses) > 0 && len(method) >= len(match) {
+			match = method
+		}
+	}
+
+	responses := t.responses[match]
+	if len(responses) == 0 {
+		return nil
+	}
+	if len(responses) > 1 {
+		t.responses[match] = responses[1:]
+	}
+
+	return responses[0]
+}
+
+// fakeStream is the TransportStream of a FakeTransport.
+type fakeStream struct {
+	transport *FakeTransport
+	endpoint  string
+	header    Metadata
+
+	mu     sync.Mutex
+	frames []*Frame
+	err    error
+	closed bool
+}
+
+func (s *fakeStream) Header() Metadata {
+	return s.header
+}
+
+func (s *fakeStream) Recv() (*Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, &Error{Code: Cancelled, Message: "stream cancelled"}
+	}
+	if len(s.frames) == 0 {
+		return nil, s.err
+	}
+
+	f := s.frames[0]
+	s.frames = s.frames[1:]
+	return f, nil
+}
+
+func (s *fakeStream) Send(msg []byte) error {
+	s.transport.mu.Lock()
+	s.transport.sent[s.endpoint] = append(s.transport.sent[s.endpoint], msg)
+	s.transport.mu.Unlock()
+
+	return nil
+}
+
+func (s *fakeStream) CloseSend() error {
+	return nil
+}
+
+func (s *fakeStream) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
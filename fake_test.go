@@ -0,0 +1,170 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"errors"
+	"testing"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+)
+
+func TestFakeTransportScript(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method,
+		&grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("first")}},
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.NotFound, Message: "not found"}},
+	)
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("last")}})
+	c := grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport))
+
+	// The responses are replayed in order, and the last one is repeated
+	for i, want := range []string{"first", "", "last", "last"} {
+		resp, err := c.RPCCall(method, message("req"))
+		if want == "" {
+			if !errors.Is(err, grpcweb.ErrCode(grpcweb.NotFound)) {
+				t.Errorf("call %d: got %v, want a NotFound error", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if string(resp) != want {
+			t.Errorf("call %d: got %q, want %q", i, resp, want)
+		}
+	}
+
+	requests := transport.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("got %d requests, want 4", len(requests))
+	}
+	for _, req := range requests {
+		if req.Endpoint != method {
+			t.Errorf("got endpoint %q, want %q", req.Endpoint, method)
+		}
+	}
+}
+
+func TestFakeTransportUnscripted(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script("/test.Service/Other", &grpcweb.FakeResponse{})
+	c := grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport))
+
+	if _, err := c.RPCCall(method, message("req")); !errors.Is(err, grpcweb.ErrCode(grpcweb.Unimplemented)) {
+		t.Errorf("got %v, want an Unimplemented error", err)
+	}
+	if n := len(transport.Requests()); n != 1 {
+		t.Errorf("got %d requests, want the unscripted request recorded", n)
+	}
+}
+
+func TestFakeTransportLongestMatch(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script("Method", &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("short")}})
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("long")}})
+	c, err := grpcweb.Dial("https://example.com", grpcweb.WithTransport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.RPCCall(method, message("req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "long" {
+		t.Errorf("got %q, want the response of the longest matching method", resp)
+	}
+}
+
+func TestFakeTransportHeaderAndErr(t *testing.T) {
+	errFault := errors.New("connection reset")
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{
+		Header:   metadata.Pairs("x-header", "value"),
+		Messages: []grpcweb.ProtoMessage{message("one"), message("two")},
+		Err:      errFault,
+	})
+	c := grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport))
+
+	var header grpcweb.Metadata
+	stream, err := c.ServerStreaming(method, message("req"), grpcweb.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// The messages are received before the error
+	for _, want := range []string{"one", "two"} {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(resp) != want {
+			t.Errorf("got %q, want %q", resp, want)
+		}
+	}
+	if _, err := stream.Recv(); !errors.Is(err, errFault) {
+		t.Errorf("got %v, want the scripted error", err)
+	}
+	if got := header.Get("x-header"); len(got) != 1 || got[0] != "value" {
+		t.Errorf("got header %v, want the scripted header", header)
+	}
+}
+
+func TestFakeTransportSent(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+	c, err := grpcweb.Dial("https://example.com", grpcweb.WithTransport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := c.BidiStreaming(method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	for _, msg := range []string{"one", "two", "three"} {
+		if err := stream.Send(message(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "resp" {
+		t.Errorf("got %q, want %q", resp, "resp")
+	}
+
+	sent := transport.Sent(method)
+	if len(sent) != 3 || string(sent[0]) != "one" || string(sent[1]) != "two" || string(sent[2]) != "three" {
+		t.Errorf("got sent messages %q, want one, two and three", sent)
+	}
+	if sent := transport.Sent("/test.Service/Other"); len(sent) != 0 {
+		t.Errorf("got %q sent to another method", sent)
+	}
+}
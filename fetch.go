@@ -0,0 +1,261 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build js && !wasm
+// +build js,!wasm
+
+package grpcweb
+
+import (
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// fetchTransport is a Transport using the Fetch API,
+// speaking the gRPC-Web protocol.
+type fetchTransport struct{}
+
+// NewFetchTransport returns a Transport that sends requests with
+// the Fetch API, using the gRPC-Web protocol. Responses are read
+// incrementally from a ReadableStream, so memory use does not grow
+// with the length of a stream, and the server is only read from
+// as fast as the client consumes messages.
+//
+// If the browser does not support streaming fetch, the
+// Transport returned by NewGRPCWebXHRTransport is returned instead.
+func NewFetchTransport() Transport {
+	if !fetchSupported() {
+		return NewGRPCWebXHRTransport()
+	}
+
+	return fetchTransport{}
+}
+
+// fetchSupported reports whether the browser supports
+// fetch with streaming responses and cancellation.
+func fetchSupported() bool {
+	for _, name := range []string{"fetch", "ReadableStream", "AbortController", "Headers", "Response"} {
+		if js.Global.Get(name) == js.Undefined {
+			return false
+		}
+	}
+
+	return js.Global.Get("Response").Get("prototype").Call("hasOwnProperty", "body").Bool()
+}
+
+// NewStream implements Transport.
+func (fetchTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	contentType, body, err := encodeBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fetchStream{
+		controller: js.Global.Get("AbortController").New(),
+		decoder:    frameDecoder{maxMsgSize: req.MaxRecvMsgSize},
+	}
+
+	headers := js.Global.Get("Headers").New()
+	headerValues(req.Header, func(k, v string) {
+		headers.Call("append", k, v)
+	})
+	headers.Call("set", "Content-Type", contentType)
+	headers.Call("set", "Accept", contentType)
+	headers.Call("set", "X-Grpc-Web", "1")
+	compressionHeaders(req.Compressor, func(k, v string) {
+		headers.Call("set", k, v)
+	})
+	if req.Timeout > 0 {
+		headers.Call("set", "grpc-timeout", encodeTimeout(req.Timeout))
+		s.timer = js.Global.Call("setTimeout", func() {
+			s.timedOut = true
+			s.controller.Call("abort")
+		}, timerMillis(req.Timeout))
+	}
+
+	var reqBody interface{} = js.Global.Get("Uint8Array").New(body)
+	if req.Encoding == TextEncoding {
+		reqBody = string(body)
+	}
+
+	s.response = js.Global.Call("fetch", req.Endpoint, js.M{
+		"method":  POST.String(),
+		"headers": headers,
+		"body":    reqBody,
+		"signal":  s.controller.Get("signal"),
+	})
+
+	return s, nil
+}
+
+// fetchStream is the TransportStream of a fetchTransport.
+type fetchStream struct {
+	controller *js.Object
+	response   *js.Object
+	timedOut   bool
+	timer      *js.Object
+
+	header     Metadata
+	httpStatus int
+	reader     *js.Object
+	eof        bool
+
+	text    bool
+	textDec base64Decoder
+	decoder frameDecoder
+	pending []*Frame
+	// err ends the stream once the pending frames are read
+	err error
+}
+
+func (s *fetchStream) Header() Metadata {
+	return s.header
+}
+
+// Recv only reads from the response body when no
+// decoded frames are pending, applying backpressure
+// to the server when the client is slow.
+func (s *fetchStream) Recv() (*Frame, error) {
+	if s.reader == nil {
+		resp, rejected := await(s.response)
+		if rejected != nil {
+			return nil, s.fetchError(rejected)
+		}
+
+		s.header = Metadata{}
+		resp.Get("headers").Call("forEach", func(value, key string) {
+			// Malformed binary values are dropped
+			_ = s.header.AppendHeader(key, value)
+		})
+		s.httpStatus = resp.Get("status").Int()
+		s.text = isTextContentType(headerValue(s.header, "content-type"))
+		s.reader = resp.Get("body").Call("getReader")
+	}
+
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		if s.eof {
+			if err := s.end(); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		result, rejected := await(s.reader.Call("read"))
+		if rejected != nil {
+			return nil, s.fetchError(rejected)
+		}
+		if result.Get("done").Bool() {
+			s.eof = true
+			continue
+		}
+
+		var err error
+		chunk := result.Get("value").Interface().([]byte)
+		if s.text {
+			chunk, err = s.textDec.decode(chunk)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+
+		s.pending, s.err = s.decoder.decode(chunk)
+		if s.err != nil {
+			s.Close()
+		}
+	}
+
+	f := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+	if f.Status != nil {
+		s.stopTimer()
+	}
+
+	return f, nil
+}
+
+// end returns the Status frame of a response that
+// ended without a trailer frame, or the error describing why it ended.
+func (s *fetchStream) end() error {
+	s.stopTimer()
+
+	// Trailers-only responses carry the status in the headers
+	if status, ok := statusFromMetadata(s.header); ok {
+		s.pending = append(s.pending, &Frame{Status: status})
+		return nil
+	}
+
+	if s.httpStatus != 200 {
+		return &Error{Code: FromHTTPStatus(s.httpStatus), Message: "unexpected HTTP status"}
+	}
+
+	return &Error{Code: Internal, Message: "response ended without a status"}
+}
+
+// fetchError converts a rejected fetch or read into an Error.
+func (s *fetchStream) fetchError(err *js.Object) error {
+	s.stopTimer()
+
+	switch {
+	case s.timedOut:
+		return &Error{Code: DeadlineExceeded, Message: "request timed out"}
+	case err.Get("name").String() == "AbortError":
+		return &Error{Code: Cancelled, Message: "stream cancelled"}
+	default:
+		return &Error{Code: Unavailable, Message: err.Get("message").String(), Err: &js.Error{Object: err}}
+	}
+}
+
+func (s *fetchStream) Close() {
+	s.controller.Call("abort")
+	s.stopTimer()
+}
+
+// stopTimer clears the timeout of the request, if any.
+func (s *fetchStream) stopTimer() {
+	if s.timer == nil {
+		return
+	}
+
+	js.Global.Call("clearTimeout", s.timer)
+	s.timer = nil
+}
+
+// await blocks until the promise p is settled. It returns the value p
+// was resolved with, or the reason it was rejected. It must not be
+// called from a JS callback.
+func await(p *js.Object) (value *js.Object, reason *js.Object) {
+	type result struct {
+		value, reason *js.Object
+	}
+
+	c := make(chan result, 1)
+	p.Call("then", func(v *js.Object) {
+		c <- result{value: v}
+	}, func(r *js.Object) {
+		c <- result{reason: r}
+	})
+
+	r := <-c
+	return r.value, r.reason
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build js && wasm
+// +build js,wasm
+
+package grpcweb
+
+import (
+	"syscall/js"
+)
+
+// fetchTransport is a Transport using the Fetch API through
+// syscall/js, speaking the gRPC-Web protocol.
+type fetchTransport struct{}
+
+// NewFetchTransport returns a Transport that sends requests with
+// the Fetch API, using the gRPC-Web protocol. Responses are read
+// incrementally from a ReadableStream, so memory use does not grow
+// with the length of a stream, and the server is only read from
+// as fast as the client consumes messages.
+func NewFetchTransport() Transport {
+	return fetchTransport{}
+}
+
+// defaultGRPCWebTransport returns the Transport
+// used by GRPCWebClientBase unless configured otherwise.
+func defaultGRPCWebTransport() Transport {
+	return NewFetchTransport()
+}
+
+// NewStream implements Transport.
+func (fetchTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	contentType, body, err := encodeBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fetchStream{
+		controller: js.Global().Get("AbortController").New(),
+		decoder:    frameDecoder{maxMsgSize: req.MaxRecvMsgSize},
+	}
+
+	headers := js.Global().Get("Headers").New()
+	headerValues(req.Header, func(k, v string) {
+		headers.Call("append", k, v)
+	})
+	headers.Call("set", "Content-Type", contentType)
+	headers.Call("set", "Accept", contentType)
+	headers.Call("set", "X-Grpc-Web", "1")
+	compressionHeaders(req.Compressor, func(k, v string) {
+		headers.Call("set", k, v)
+	})
+	if req.Timeout > 0 {
+		headers.Call("set", "grpc-timeout", encodeTimeout(req.Timeout))
+		s.onTimeout = js.FuncOf(func(js.Value, []js.Value) interface{} {
+			s.timedOut = true
+			s.controller.Call("abort")
+			return nil
+		})
+		s.timer = js.Global().Call("setTimeout", s.onTimeout, timerMillis(req.Timeout))
+	}
+
+	var reqBody interface{} = string(body)
+	if req.Encoding != TextEncoding {
+		reqBody = uint8Array(body)
+	}
+
+	s.response = js.Global().Call("fetch", req.Endpoint, map[string]interface{}{
+		"method":  "POST",
+		"headers": headers,
+		"body":    reqBody,
+		"signal":  s.controller.Get("signal"),
+	})
+
+	return s, nil
+}
+
+// fetchStream is the TransportStream of a fetchTransport.
+type fetchStream struct {
+	controller js.Value
+	response   js.Value
+	timedOut   bool
+	timer      js.Value
+	onTimeout  js.Func
+
+	header     Metadata
+	httpStatus int
+	reader     js.Value
+	eof        bool
+	stopped    bool
+
+	text    bool
+	textDec base64Decoder
+	decoder frameDecoder
+	pending []*Frame
+	// err ends the stream once the pending frames are read
+	err error
+}
+
+func (s *fetchStream) Header() Metadata {
+	return s.header
+}
+
+// Recv only reads from the response body when no
+// decoded frames are pending, applying backpressure
+// to the server when the client is slow.
+func (s *fetchStream) Recv() (*Frame, error) {
+	if s.reader.IsUndefined() {
+		resp, ok := await(s.response)
+		if !ok {
+			return nil, s.fetchError(resp)
+		}
+
+		s.header = Metadata{}
+		forEach := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			// Malformed binary values are dropped
+			_ = s.header.AppendHeader(args[1].String(), args[0].String())
+			return nil
+		})
+		resp.Get("headers").Call("forEach", forEach)
+		forEach.Release()
+
+		s.httpStatus = resp.Get("status").Int()
+		s.text = isTextContentType(headerValue(s.header, "content-type"))
+		s.reader = resp.Get("body").Call("getReader")
+	}
+
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		if s.eof {
+			if err := s.end(); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		result, ok := await(s.reader.Call("read"))
+		if !ok {
+			return nil, s.fetchError(result)
+		}
+		if result.Get("done").Bool() {
+			s.eof = true
+			continue
+		}
+
+		value := result.Get("value")
+		chunk := make([]byte, value.Get("length").Int())
+		js.CopyBytesToGo(chunk, value)
+
+		var err error
+		if s.text {
+			chunk, err = s.textDec.decode(chunk)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+
+		s.pending, s.err = s.decoder.decode(chunk)
+		if s.err != nil {
+			s.Close()
+		}
+	}
+
+	f := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+	if f.Status != nil {
+		s.stopTimer()
+	}
+
+	return f, nil
+}
+
+// end returns the Status frame of a response that
+// ended without a trailer frame, or the error describing why it ended.
+func (s *fetchStream) end() error {
+	s.stopTimer()
+
+	// Trailers-only responses carry the status in the headers
+	if status, ok := statusFromMetadata(s.header); ok {
+		s.pending = append(s.pending, &Frame{Status: status})
+		return nil
+	}
+
+	if s.httpStatus != 200 {
+		return &Error{Code: FromHTTPStatus(s.httpStatus), Message: "unexpected HTTP status"}
+	}
+
+	return &Error{Code: Internal, Message: "response ended without a status"}
+}
+
+// fetchError converts a rejected fetch or read into an Error.
+func (s *fetchStream) fetchError(err js.Value) error {
+	s.stopTimer()
+
+	switch {
+	case s.timedOut:
+		return &Error{Code: DeadlineExceeded, Message: "request timed out"}
+	case err.Get("name").String() == "AbortError":
+		return &Error{Code: Cancelled, Message: "stream cancelled"}
+	default:
+		return &Error{Code: Unavailable, Message: err.Get("message").String(), Err: js.Error{Value: err}}
+	}
+}
+
+func (s *fetchStream) Close() {
+	s.controller.Call("abort")
+	s.stopTimer()
+}
+
+// stopTimer clears the timeout of the request, if any,
+// and releases its callback.
+func (s *fetchStream) stopTimer() {
+	if s.timer.IsUndefined() || s.stopped {
+		return
+	}
+
+	s.stopped = true
+	js.Global().Call("clearTimeout", s.timer)
+	s.onTimeout.Release()
+}
+
+// uint8Array copies b to a new JS Uint8Array.
+func uint8Array(b []byte) js.Value {
+	a := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(a, b)
+	return a
+}
+
+// await blocks until the promise p is settled. It returns the value p
+// was resolved with and true, or the reason it was rejected and false.
+// It must not be called from a JS callback.
+func await(p js.Value) (js.Value, bool) {
+	type result struct {
+		value    js.Value
+		resolved bool
+	}
+
+	c := make(chan result, 1)
+	resolve := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		c <- result{value: args[0], resolved: true}
+		return nil
+	})
+	reject := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		c <- result{value: args[0]}
+		return nil
+	})
+	defer resolve.Release()
+	defer reject.Release()
+
+	p.Call("then", resolve, reject)
+
+	r := <-c
+	return r.value, r.resolved
+}
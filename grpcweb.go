@@ -0,0 +1,383 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+)
+
+// GRPCWebClientBase is a client speaking the standard gRPC-Web
+// protocol, as implemented by Envoy and the improbable-eng grpcweb
+// Go wrapper. It offers the same methods as GatewayClientBase.
+type GRPCWebClientBase struct {
+	clientBase
+}
+
+// NewGRPCWebClientBase constructs a new GRPCWebClientBase.
+// Unless configured with WithTransport, it uses the Transport
+// returned by NewGRPCWebXHRTransport in the browser, the one returned
+// by NewNodeTransport in Node.js, and the one returned by
+// NewHTTPTransport(nil) outside of JS.
+func NewGRPCWebClientBase(opts ...ClientOption) *GRPCWebClientBase {
+	return &GRPCWebClientBase{
+		clientBase: newClientBase(defaultGRPCWebTransport(), opts),
+	}
+}
+
+// Encoding is the encoding of the request and
+// response bodies in the gRPC-Web protocol.
+type Encoding int
+
+const (
+	// BinaryEncoding sends bodies as binary, using the
+	// application/grpc-web+proto content type.
+	BinaryEncoding = Encoding(iota)
+
+	// TextEncoding sends bodies as base64 text, using the
+	// application/grpc-web-text content type. Use it when
+	// proxies between the client and server corrupt binary bodies.
+	TextEncoding
+)
+
+// WithEncoding sets the Encoding used by the gRPC-Web protocol.
+// The default is BinaryEncoding. Transports for protocols with
+// a fixed encoding ignore it.
+func WithEncoding(e Encoding) ClientOption {
+	return func(c *clientBase) {
+		c.encoding = e
+	}
+}
+
+// gRPC-Web protocol constants
+const (
+	grpcWebContentType     = "application/grpc-web+proto"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	frameHeaderLen = 5
+	trailerFlag    = 0x80
+	compressedFlag = 0x01
+
+	statusHeader        = "grpc-status"
+	messageHeader       = "grpc-message"
+	statusDetailsHeader = "grpc-status-details-bin"
+)
+
+// maxTrailerSize is the largest trailer or header frame, or
+// gateway status, accepted. It is the default limit of gRPC
+// on the size of the headers or trailers of a call.
+const maxTrailerSize = 16 << 20
+
+// maxInt is the largest int, which only has 32 bits with GopherJS.
+const maxInt = int(^uint(0) >> 1)
+
+// checkFrameSize returns an error if a frame or field with the
+// length can't be accepted. Messages are limited to maxMsgSize,
+// unless it is 0 or less, and other payloads to maxTrailerSize.
+// Every length is limited to what can be sliced after the
+// frame header of headerLen bytes.
+func checkFrameSize(length uint64, headerLen int, message bool, maxMsgSize int) error {
+	limit := uint64(maxInt - headerLen)
+	if !message {
+		if limit > maxTrailerSize {
+			limit = maxTrailerSize
+		}
+		if length > limit {
+			return &Error{Code: ResourceExhausted, Message: "received trailers larger than max (" +
+				strconv.FormatUint(length, 10) + " vs. " + strconv.FormatUint(limit, 10) + ")"}
+		}
+		return nil
+	}
+
+	if maxMsgSize > 0 && uint64(maxMsgSize) < limit {
+		limit = uint64(maxMsgSize)
+	}
+	if length > limit {
+		return errRecvSize(length, int(limit))
+	}
+
+	return nil
+}
+
+// encodeFrame returns msg as a length-prefixed gRPC-Web message
+// frame. If c is not nil, the message is compressed with c.
+func encodeFrame(msg []byte, c Compressor) ([]byte, error) {
+	var flags byte
+	if c != nil {
+		var err error
+		if msg, err = compress(c, msg); err != nil {
+			return nil, err
+		}
+		flags = compressedFlag
+	}
+
+	frame := make([]byte, frameHeaderLen+len(msg))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:frameHeaderLen], uint32(len(msg)))
+	copy(frame[frameHeaderLen:], msg)
+
+	return frame, nil
+}
+
+// encodeBody returns the content type and the body of the request,
+// using the encoding and compressor of the request.
+func encodeBody(req *TransportRequest) (string, []byte, error) {
+	frame, err := encodeFrame(req.Body, req.Compressor)
+	if err != nil {
+		return "", nil, err
+	}
+	if req.Encoding == TextEncoding {
+		body := make([]byte, base64.StdEncoding.EncodedLen(len(frame)))
+		base64.StdEncoding.Encode(body, frame)
+		return grpcWebTextContentType, body, nil
+	}
+
+	return grpcWebContentType, frame, nil
+}
+
+// isTextContentType reports whether a response with the
+// content type ct is encoded with TextEncoding.
+func isTextContentType(ct string) bool {
+	return strings.HasPrefix(ct, grpcWebTextContentType)
+}
+
+// base64Decoder decodes base64 text received in chunks with arbitrary
+// boundaries. The text may be a concatenation of several padded
+// base64 strings, as servers encode each write separately.
+type base64Decoder struct {
+	buf []byte
+}
+
+// decode adds the chunk to the text received so far and returns
+// the bytes of all complete 4 character quanta.
+func (d *base64Decoder) decode(chunk []byte) ([]byte, error) {
+	for _, c := range chunk {
+		// Skip any line breaks inserted by the server
+		if c != '\r' && c != '\n' {
+			d.buf = append(d.buf, c)
+		}
+	}
+
+	n := len(d.buf) / 4 * 4
+	out := make([]byte, 0, n/4*3)
+	var quantum [3]byte
+	for i := 0; i < n; i += 4 {
+		// Quanta are decoded one at a time since
+		// padding may appear in the middle of the text.
+		m, err := base64.StdEncoding.Decode(quantum[:], d.buf[i:i+4])
+		if err != nil {
+			return out, &Error{Code: Internal, Message: "malformed base64 response: " + err.Error(), Err: err}
+		}
+		out = append(out, quantum[:m]...)
+	}
+	d.buf = append(d.buf[:0], d.buf[n:]...)
+
+	return out, nil
+}
+
+// frameDecoder decodes gRPC-Web frames from a response body
+// that is received in chunks with arbitrary boundaries.
+type frameDecoder struct {
+	buf []byte
+
+	// maxMsgSize is the largest message accepted,
+	// or 0 or less if there is no limit.
+	maxMsgSize int
+
+	// headerFrame is set if the first trailer flagged frame
+	// holds the response headers, as in the websocket protocol.
+	headerFrame bool
+	header      Metadata
+}
+
+// decode adds the chunk to the data received so far and
+// returns all the frames that have been completely received.
+// The trailer frame is returned as a Status frame.
+func (d *frameDecoder) decode(chunk []byte) ([]*Frame, error) {
+	d.buf = append(d.buf, chunk...)
+
+	var frames []*Frame
+	for len(d.buf) >= frameHeaderLen {
+		flags := d.buf[0]
+		rawLength := uint64(binary.BigEndian.Uint32(d.buf[1:frameHeaderLen]))
+		// Checked before the frame is buffered or sliced
+		if err := checkFrameSize(rawLength, frameHeaderLen, flags&trailerFlag == 0, d.maxMsgSize); err != nil {
+			return frames, err
+		}
+		length := int(rawLength)
+		if len(d.buf) < frameHeaderLen+length {
+			break
+		}
+
+		payload := d.buf[frameHeaderLen : frameHeaderLen+length]
+		d.buf = d.buf[frameHeaderLen+length:]
+
+		switch {
+		case flags&trailerFlag != 0 && d.headerFrame && d.header == nil:
+			d.header = parseHeaderBlock(payload)
+		case flags&trailerFlag != 0:
+			status, ok := statusFromMetadata(parseHeaderBlock(payload))
+			if !ok {
+				return frames, &Error{Code: Internal, Message: "trailer frame without " + statusHeader}
+			}
+			frames = append(frames, &Frame{Status: status})
+		default:
+			// Copy the payload so that it doesn't share memory with d.buf
+			msg := make([]byte, len(payload))
+			copy(msg, payload)
+			frames = append(frames, &Frame{Message: msg, Compressed: flags&compressedFlag != 0})
+		}
+	}
+
+	return frames, nil
+}
+
+// parseHeaderBlock parses an HTTP/1 style header block, as found
+// in trailer frames, into Metadata. Repeated keys are kept as
+// multiple values, and the values of binary keys are decoded.
+func parseHeaderBlock(b []byte) Metadata {
+	m := Metadata{}
+	for _, line := range strings.Split(string(b), "\r\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		// Malformed binary values are dropped
+		_ = m.AppendHeader(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+
+	return m
+}
+
+// headerValue returns the first value of the key k in m, or "".
+func headerValue(m Metadata, k string) string {
+	if v := m.Get(k); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// headerValues calls set with each value of m, encoded as a header value.
+func headerValues(m Metadata, set func(k, v string)) {
+	for k, vals := range m {
+		for _, v := range vals {
+			set(k, metadata.EncodeValue(k, v))
+		}
+	}
+}
+
+// statusFromMetadata creates a Status from the grpc-status,
+// grpc-message and grpc-status-details-bin entries of m. The remaining
+// entries become the Metadata of the Status. It returns false if m has
+// no grpc-status.
+func statusFromMetadata(m Metadata) (*Status, bool) {
+	rawCode := m.Get(statusHeader)
+	if len(rawCode) == 0 {
+		return nil, false
+	}
+
+	code := Unknown
+	if n, err := strconv.ParseInt(rawCode[0], 10, 64); err == nil {
+		code = wireStatusCode(n)
+	}
+
+	message := headerValue(m, messageHeader)
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+
+	var details []Any
+	if v := m.Get(statusDetailsHeader); len(v) > 0 {
+		st, err := ParseRPCStatus([]byte(v[0]))
+		if err != nil {
+			code = Internal
+			message = "malformed " + statusDetailsHeader + ": " + err.Error()
+		} else {
+			details = st.Details()
+		}
+	}
+
+	md := Metadata{}
+	for k, v := range m {
+		if k != statusHeader && k != messageHeader && k != statusDetailsHeader {
+			md[k] = v
+		}
+	}
+
+	return &Status{
+		Code:     code,
+		Message:  message,
+		Metadata: md,
+		details:  details,
+	}, true
+}
+
+// maxTimeoutValue is the largest value of a grpc-timeout
+// header, which is limited to 8 digits.
+const maxTimeoutValue = 1e8 - 1
+
+// encodeTimeout formats t as the value of a grpc-timeout header.
+// It is rounded up to whole milliseconds, or to the finest
+// coarser unit whose value fits in 8 digits.
+func encodeTimeout(t time.Duration) string {
+	value, unit := timeoutMillis(t), "m"
+	for _, u := range []struct {
+		factor int64
+		unit   string
+	}{{1000, "S"}, {60, "M"}, {60, "H"}} {
+		if value <= maxTimeoutValue {
+			break
+		}
+		value, unit = (value+u.factor-1)/u.factor, u.unit
+	}
+
+	return strconv.FormatInt(value, 10) + unit
+}
+
+// timeoutMillis returns t in milliseconds, rounded up so
+// that timeouts shorter than a millisecond aren't 0.
+func timeoutMillis(t time.Duration) int64 {
+	ms := int64(t / time.Millisecond)
+	if t%time.Millisecond > 0 {
+		ms++
+	}
+
+	return ms
+}
+
+// timerMillis returns the delay of a JavaScript timer for t. It is
+// rounded up like timeoutMillis, and capped at the longest delay
+// of setTimeout, beyond which timers fire immediately.
+func timerMillis(t time.Duration) int {
+	if ms := timeoutMillis(t); ms < math.MaxInt32 {
+		return int(ms)
+	}
+
+	return math.MaxInt32
+}
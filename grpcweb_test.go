@@ -0,0 +1,304 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// testFrame returns a frame with the flags and payload
+func testFrame(flags byte, payload []byte) []byte {
+	b := make([]byte, frameHeaderLen+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:frameHeaderLen], uint32(len(payload)))
+	copy(b[frameHeaderLen:], payload)
+	return b
+}
+
+// frameHeader returns only the header of a frame of the length
+func frameHeader(flags byte, length uint32) []byte {
+	b := make([]byte, frameHeaderLen)
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], length)
+	return b
+}
+
+func TestFrameDecoder(t *testing.T) {
+	body := append(testFrame(0, []byte("one")), testFrame(compressedFlag, []byte("two"))...)
+	body = append(body, testFrame(trailerFlag, []byte("grpc-status: 5\r\ngrpc-message: gone\r\nx-trailer: value\r\n"))...)
+
+	// Chunk boundaries don't matter
+	for size := 1; size <= len(body); size++ {
+		var d frameDecoder
+		var frames []*Frame
+		for i := 0; i < len(body); i += size {
+			end := i + size
+			if end > len(body) {
+				end = len(body)
+			}
+			fs, err := d.decode(body[i:end])
+			if err != nil {
+				t.Fatalf("chunks of %d bytes: %v", size, err)
+			}
+			frames = append(frames, fs...)
+		}
+
+		if len(frames) != 3 {
+			t.Fatalf("chunks of %d bytes: got %d frames, want 3", size, len(frames))
+		}
+		if string(frames[0].Message) != "one" || frames[0].Compressed {
+			t.Errorf("chunks of %d bytes: got first frame %q, want %q", size, frames[0].Message, "one")
+		}
+		if string(frames[1].Message) != "two" || !frames[1].Compressed {
+			t.Errorf("chunks of %d bytes: got second frame %q, want compressed %q", size, frames[1].Message, "two")
+		}
+		st := frames[2].Status
+		if st == nil || st.Code != NotFound || st.Message != "gone" || headerValue(st.Metadata, "x-trailer") != "value" {
+			t.Errorf("chunks of %d bytes: got status %+v, want NotFound %q with a trailer", size, st, "gone")
+		}
+	}
+}
+
+func TestBase64Decoder(t *testing.T) {
+	// Separately padded writes, with line breaks inserted by the server
+	var text []byte
+	var want []byte
+	for i, part := range []string{"one", "hello", "x", "grpc-web"} {
+		if i > 0 {
+			text = append(text, "\r\n"...)
+		}
+		text = append(text, base64.StdEncoding.EncodeToString([]byte(part))...)
+		want = append(want, part...)
+	}
+	text = append(text[:6:6], append([]byte("\r\n"), text[6:]...)...)
+
+	decodeAll := func(chunks ...[]byte) ([]byte, error) {
+		var d base64Decoder
+		var out []byte
+		for _, chunk := range chunks {
+			b, err := d.decode(chunk)
+			out = append(out, b...)
+			if err != nil {
+				return out, err
+			}
+		}
+		if len(d.buf) != 0 {
+			return out, fmt.Errorf("%q left undecoded", d.buf)
+		}
+		return out, nil
+	}
+
+	// Split at every offset
+	for i := 0; i <= len(text); i++ {
+		got, err := decodeAll(text[:i], text[i:])
+		if err != nil {
+			t.Fatalf("split at %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("split at %d: got %q, want %q", i, got, want)
+		}
+	}
+
+	// One byte at a time
+	var chunks [][]byte
+	for i := range text {
+		chunks = append(chunks, text[i:i+1])
+	}
+	if got, err := decodeAll(chunks...); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("one byte at a time: got %q, %v, want %q", got, err, want)
+	}
+
+	for _, malformed := range []string{"b25l!GVsbG8=", "b===", "b25lIQ=a"} {
+		_, err := decodeAll([]byte(malformed))
+		if !errors.Is(err, ErrCode(Internal)) {
+			t.Errorf("decoding %q: got %v, want an Internal error", malformed, err)
+		}
+	}
+}
+
+func TestStatusFromMetadataCodes(t *testing.T) {
+	for _, tt := range []struct {
+		code string
+		want StatusCode
+	}{
+		{"0", Ok},
+		{"5", NotFound},
+		{"16", Unauthenticated},
+		// Codes not defined by gRPC are Unknown
+		{"17", Unknown},
+		{"-1", Unknown},
+		{"4294967301", Unknown},
+		{"99999999999999999999", Unknown},
+		{"NOT_FOUND", Unknown},
+		{"", Unknown},
+	} {
+		st, ok := statusFromMetadata(Metadata{statusHeader: {tt.code}})
+		if !ok {
+			t.Fatalf("code %q: no status", tt.code)
+		}
+		if st.Code != tt.want {
+			t.Errorf("code %q: got %v, want %v", tt.code, st.Code, tt.want)
+		}
+	}
+}
+
+func TestFrameDecoderLimits(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		maxMsgSize int
+		chunk      []byte
+		// wantErr is set if the frame is rejected
+		wantErr bool
+	}{
+		{"message at the limit", 3, testFrame(0, []byte("one")), false},
+		{"message over the limit", 2, frameHeader(0, 3), true},
+		{"no limit", 0, testFrame(0, make([]byte, DefaultMaxRecvMsgSize+1)), false},
+		{"negative limit", -1, testFrame(0, make([]byte, DefaultMaxRecvMsgSize+1)), false},
+		{"trailers under the message limit", 1, testFrame(trailerFlag, []byte("grpc-status: 0\r\n")), false},
+		{"trailers over the trailer limit", 0, frameHeader(trailerFlag, maxTrailerSize+1), true},
+		{"trailers with a huge length", DefaultMaxRecvMsgSize, frameHeader(trailerFlag, 0xffffffff), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := frameDecoder{maxMsgSize: tt.maxMsgSize}
+			// The size is checked before the payload is received
+			_, err := d.decode(tt.chunk)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrCode(ResourceExhausted)) {
+				t.Fatalf("got %v, want a ResourceExhausted error", err)
+			}
+		})
+	}
+}
+
+func TestCheckFrameSize(t *testing.T) {
+	// Lengths that can't be sliced after the header are
+	// rejected even without a limit, which matters when
+	// int has 32 bits, as with GopherJS.
+	if err := checkFrameSize(uint64(maxInt), frameHeaderLen, true, 0); err == nil {
+		t.Error("accepted a message length overflowing int")
+	}
+	if err := checkFrameSize(uint64(maxInt-frameHeaderLen), frameHeaderLen, true, 0); err != nil {
+		t.Errorf("rejected the largest message length: %v", err)
+	}
+	if err := checkFrameSize(maxTrailerSize, frameHeaderLen, false, 1); err != nil {
+		t.Errorf("rejected trailers at the limit: %v", err)
+	}
+}
+
+func TestCheckSendSize(t *testing.T) {
+	msg := make([]byte, 10)
+	for _, tt := range []struct {
+		max     int
+		wantErr bool
+	}{
+		{9, true},
+		{10, false},
+		{0, false},
+		{-1, false},
+	} {
+		ci := newCallInfo([]CallOption{MaxCallSendMsgSize(tt.max)})
+		if err := ci.checkSendSize(msg); (err != nil) != tt.wantErr {
+			t.Errorf("MaxCallSendMsgSize(%d): got %v sending %d bytes", tt.max, err, len(msg))
+		}
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	msg := bytes.Repeat([]byte("a"), 100)
+	compressed, err := compress(GetCompressor("gzip"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := Metadata{encodingHeader: {"gzip"}}
+
+	for _, tt := range []struct {
+		maxSize int
+		wantErr bool
+	}{
+		{99, true},
+		{100, false},
+		{0, false},
+		{-1, false},
+	} {
+		got, err := decompress(header, compressed, tt.maxSize)
+		switch {
+		case tt.wantErr && !errors.Is(err, ErrCode(ResourceExhausted)):
+			t.Errorf("maxSize %d: got %v, want a ResourceExhausted error", tt.maxSize, err)
+		case !tt.wantErr && err != nil:
+			t.Errorf("maxSize %d: %v", tt.maxSize, err)
+		case !tt.wantErr && !bytes.Equal(got, msg):
+			t.Errorf("maxSize %d: got %d bytes, want %d", tt.maxSize, len(got), len(msg))
+		}
+	}
+}
+
+func TestEncodeTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{time.Nanosecond, "1m"},
+		{time.Millisecond, "1m"},
+		{1500 * time.Microsecond, "2m"},
+		{time.Minute, "60000m"},
+		{99999999 * time.Millisecond, "99999999m"},
+		{99999999*time.Millisecond + 1, "100000S"},
+		{99999999 * time.Second, "99999999S"},
+		{100000000 * time.Second, "1666667M"},
+		{99999999 * time.Minute, "99999999M"},
+		{100000000 * time.Minute, "1666667H"},
+		{math.MaxInt64, "2562048H"},
+	} {
+		if got := encodeTimeout(tt.timeout); got != tt.want {
+			t.Errorf("encodeTimeout(%v) = %q, want %q", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestTimerMillis(t *testing.T) {
+	for _, tt := range []struct {
+		timeout time.Duration
+		want    int
+	}{
+		{time.Nanosecond, 1},
+		{time.Millisecond, 1},
+		{1500 * time.Microsecond, 2},
+		{time.Second, 1000},
+		{math.MaxInt32 * time.Millisecond, math.MaxInt32},
+		{math.MaxInt64, math.MaxInt32},
+	} {
+		if got := timerMillis(tt.timeout); got != tt.want {
+			t.Errorf("timerMillis(%v) = %d, want %d", tt.timeout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcwebtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+// trailerPrefix marks headers set as trailers after
+// the response headers have been written, see net/http.
+const trailerPrefix = "Trailer:"
+
+// serveGRPC serves the call with the *grpc.Server, translating
+// its gRPC response to the protocol spoken by the client.
+func serveGRPC(s *grpc.Server, resp responder, r *http.Request, req []byte) {
+	body := make([]byte, 5+len(req))
+	binary.BigEndian.PutUint32(body[1:5], uint32(len(req)))
+	copy(body[5:], req)
+
+	// The *grpc.Server only accepts HTTP/2 gRPC requests
+	gr := r.Clone(r.Context())
+	gr.Proto, gr.ProtoMajor, gr.ProtoMinor = "HTTP/2", 2, 0
+	gr.Header.Set("Content-Type", "application/grpc+proto")
+	// The request message has already been decompressed
+	gr.Header.Del(encodingHeader)
+	gr.Header.Del("Content-Length")
+	gr.ContentLength = int64(len(body))
+	gr.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	w := &grpcResponseWriter{
+		resp:   resp,
+		header: http.Header{},
+	}
+	s.ServeHTTP(w, gr)
+	w.finish()
+}
+
+// grpcResponseWriter is the http.ResponseWriter passed to the
+// *grpc.Server. It decodes the gRPC frames written to it.
+type grpcResponseWriter struct {
+	resp        responder
+	header      http.Header
+	wroteHeader bool
+	buf         []byte
+	err         error
+}
+
+func (w *grpcResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	md := grpcweb.Metadata{}
+	for k, v := range w.header {
+		switch {
+		case k == "Content-Type", k == "Trailer", len(v) == 0:
+			continue
+		}
+		// Malformed binary values are dropped
+		_ = md.AppendHeader(k, v...)
+	}
+	w.resp.writeHeader(md)
+}
+
+func (w *grpcResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, b...)
+	for len(w.buf) >= 5 {
+		length := int(binary.BigEndian.Uint32(w.buf[1:5]))
+		if len(w.buf) < 5+length {
+			break
+		}
+
+		msg := w.buf[5 : 5+length]
+		w.buf = w.buf[5+length:]
+		if w.err = w.resp.writeMessage(msg); w.err != nil {
+			return 0, w.err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush implements http.Flusher, which the *grpc.Server requires.
+// Messages are flushed to the client as they are decoded.
+func (w *grpcResponseWriter) Flush() {}
+
+// finish writes the status of the call from the trailers
+// set by the *grpc.Server.
+func (w *grpcResponseWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+
+	declared := map[string]bool{}
+	for _, v := range w.header["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			declared[http.CanonicalHeaderKey(strings.TrimSpace(k))] = true
+		}
+	}
+
+	trailer := grpcweb.Metadata{}
+	for k, v := range w.header {
+		switch {
+		case strings.HasPrefix(k, trailerPrefix):
+			k = strings.TrimPrefix(k, trailerPrefix)
+		case !declared[k]:
+			continue
+		}
+		// Malformed binary values are dropped
+		_ = trailer.AppendHeader(k, v...)
+	}
+
+	code, err := strconv.Atoi(firstValue(trailer, "grpc-status"))
+	if err != nil {
+		w.resp.writeStatus(&grpcweb.Status{Code: grpcweb.Unknown, Message: "server did not send a status"})
+		return
+	}
+	message, err := url.PathUnescape(firstValue(trailer, "grpc-message"))
+	if err != nil {
+		message = firstValue(trailer, "grpc-message")
+	}
+	rpcStatus, err := grpcweb.ParseRPCStatus([]byte(firstValue(trailer, statusDetailsHeader)))
+	if err != nil {
+		w.resp.writeStatus(&grpcweb.Status{Code: grpcweb.Internal, Message: "malformed " + statusDetailsHeader + ": " + err.Error()})
+		return
+	}
+	trailer.Delete("grpc-status")
+	trailer.Delete("grpc-message")
+	trailer.Delete(statusDetailsHeader)
+
+	st := &grpcweb.Status{Code: grpcweb.StatusCode(code), Message: message, Metadata: trailer}
+	w.resp.writeStatus(st.WithDetails(rpcStatus.Details()...))
+}
+
+// firstValue returns the first value of the key k in md, or "".
+func firstValue(md grpcweb.Metadata, k string) string {
+	if v := md.Get(k); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcwebtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+// pairTypeURL is the type URL of the grpc.gateway.Pair
+// messages holding the trailers of a gateway status.
+const pairTypeURL = "type.googleapis.com/grpc.gateway.Pair"
+
+// statusDetailsHeader is the trailer holding the
+// google.rpc.Status of a gRPC-Web call with details.
+const statusDetailsHeader = "grpc-status-details-bin"
+
+// Compression constants of the gRPC-Web protocol
+const (
+	compressedFlag       = 0x01
+	encodingHeader       = "grpc-encoding"
+	acceptEncodingHeader = "grpc-accept-encoding"
+)
+
+// errAborted is returned when sending on an aborted call.
+var errAborted = errors.New("call aborted")
+
+// responder writes the response of a call in the
+// protocol spoken by the client. Only the first
+// call to writeHeader has an effect.
+type responder interface {
+	writeHeader(md grpcweb.Metadata)
+	writeMessage(msg []byte) error
+	writeStatus(st *grpcweb.Status)
+}
+
+// grpcWebResponder speaks the gRPC-Web protocol.
+type grpcWebResponder struct {
+	w           http.ResponseWriter
+	text        bool
+	compressor  grpcweb.Compressor
+	wroteHeader bool
+}
+
+func (r *grpcWebResponder) writeHeader(md grpcweb.Metadata) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+
+	h := r.w.Header()
+	setHeader(h, md)
+	if r.compressor != nil {
+		h.Set(encodingHeader, r.compressor.Name())
+	}
+	if r.text {
+		h.Set("Content-Type", "application/grpc-web-text")
+	} else {
+		h.Set("Content-Type", "application/grpc-web+proto")
+	}
+	r.w.WriteHeader(http.StatusOK)
+}
+
+func (r *grpcWebResponder) writeMessage(msg []byte) error {
+	r.writeHeader(nil)
+	if r.compressor == nil {
+		return r.writeFrame(0x00, msg)
+	}
+
+	var buf bytes.Buffer
+	cw, err := r.compressor.Compress(&buf)
+	if err != nil {
+		return err
+	}
+	if _, err = cw.Write(msg); err == nil {
+		err = cw.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.writeFrame(compressedFlag, buf.Bytes())
+}
+
+func (r *grpcWebResponder) writeStatus(st *grpcweb.Status) {
+	r.writeHeader(nil)
+
+	var block strings.Builder
+	block.WriteString("grpc-status: " + strconv.Itoa(int(st.Code)) + "\r\n")
+	if st.Message != "" {
+		block.WriteString("grpc-message: " + url.PathEscape(st.Message) + "\r\n")
+	}
+	if len(st.Details()) > 0 {
+		block.WriteString(statusDetailsHeader + ": " + metadata.EncodeValue(statusDetailsHeader, string(encodeStatus(st, nil))) + "\r\n")
+	}
+	for k, vals := range st.Metadata {
+		for _, v := range vals {
+			block.WriteString(k + ": " + metadata.EncodeValue(k, v) + "\r\n")
+		}
+	}
+	r.writeFrame(0x80, []byte(block.String()))
+}
+
+func (r *grpcWebResponder) writeFrame(flags byte, payload []byte) error {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	if r.text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+
+	return write(r.w, frame)
+}
+
+// gatewayResponder speaks the gRPC-gateway streaming protocol.
+type gatewayResponder struct {
+	w           http.ResponseWriter
+	base64      bool
+	wroteHeader bool
+}
+
+func (r *gatewayResponder) writeHeader(md grpcweb.Metadata) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+
+	h := r.w.Header()
+	setHeader(h, md)
+	h.Set("Content-Type", "application/x-protobuf")
+	if r.base64 {
+		h.Set("Content-Transfer-Encoding", "base64")
+	}
+	r.w.WriteHeader(http.StatusOK)
+}
+
+func (r *gatewayResponder) writeMessage(msg []byte) error {
+	r.writeHeader(nil)
+	return r.writeField(1, msg)
+}
+
+func (r *gatewayResponder) writeStatus(st *grpcweb.Status) {
+	r.writeHeader(nil)
+
+	// The trailers are sent as grpc.gateway.Pair details
+	r.writeField(2, encodeStatus(st, st.Metadata))
+}
+
+// encodeStatus encodes a google.rpc.Status with the code, message and
+// details of st, and each value of pairs as a grpc.gateway.Pair detail.
+func encodeStatus(st *grpcweb.Status, pairs grpcweb.Metadata) []byte {
+	details := st.Details()
+	for k, vals := range pairs {
+		for _, v := range vals {
+			pair := wire.NewBuffer(nil)
+			pair.EncodeTag(1, wire.Bytes)
+			pair.EncodeString(k)
+			pair.EncodeTag(2, wire.Bytes)
+			pair.EncodeString(v)
+			details = append(details, grpcweb.Any{TypeURL: pairTypeURL, Value: pair.Bytes()})
+		}
+	}
+
+	status := wire.NewBuffer(nil)
+	status.EncodeTag(1, wire.Varint)
+	status.EncodeVarint(uint64(st.Code))
+	status.EncodeTag(2, wire.Bytes)
+	status.EncodeString(st.Message)
+	for i := range details {
+		status.EncodeTag(3, wire.Bytes)
+		// Encoding an Any never fails
+		_ = status.EncodeMessage(&details[i])
+	}
+
+	return status.Bytes()
+}
+
+// setHeader adds the values of md to h, encoded as header values.
+func setHeader(h http.Header, md grpcweb.Metadata) {
+	for k, vals := range md {
+		for _, v := range vals {
+			h.Add(k, metadata.EncodeValue(k, v))
+		}
+	}
+}
+
+func (r *gatewayResponder) writeField(field int32, payload []byte) error {
+	b := wire.NewBuffer(nil)
+	b.EncodeTag(field, wire.Bytes)
+	b.EncodeBytes(payload)
+	out := b.Bytes()
+	if r.base64 {
+		out = []byte(base64.StdEncoding.EncodeToString(out))
+	}
+
+	return write(r.w, out)
+}
+
+// decompress decompresses a request message with the Compressor
+// registered with the name. It returns the status ending the
+// call if the message can't be decompressed.
+func decompress(name string, msg []byte) ([]byte, *grpcweb.Status) {
+	c := grpcweb.GetCompressor(name)
+	if c == nil {
+		return nil, &grpcweb.Status{Code: grpcweb.Unimplemented, Message: "unsupported " + encodingHeader + " " + name}
+	}
+
+	r, err := c.Decompress(bytes.NewReader(msg))
+	if err == nil {
+		msg, err = ioutil.ReadAll(r)
+	}
+	if err != nil {
+		return nil, &grpcweb.Status{Code: grpcweb.Internal, Message: "decompressing request: " + err.Error()}
+	}
+
+	return msg, nil
+}
+
+// write writes b to w and flushes it to the client.
+func write(w http.ResponseWriter, b []byte) error {
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+// faultResponder injects a Fault into the response of a call.
+type faultResponder struct {
+	responder
+	fault   *Fault
+	sent    int
+	aborted bool
+}
+
+func (r *faultResponder) writeMessage(msg []byte) error {
+	if r.aborted {
+		return errAborted
+	}
+	if r.fault == nil {
+		return r.responder.writeMessage(msg)
+	}
+
+	if r.fault.Abort && r.sent == r.fault.AbortAfter {
+		r.aborted = true
+		return errAborted
+	}
+	time.Sleep(r.fault.MessageDelay)
+	r.sent++
+
+	return r.responder.writeMessage(msg)
+}
+
+func (r *faultResponder) writeStatus(st *grpcweb.Status) {
+	if r.aborted {
+		return
+	}
+	if r.fault == nil {
+		r.responder.writeStatus(st)
+		return
+	}
+
+	if r.fault.Abort {
+		r.aborted = true
+		return
+	}
+	if len(r.fault.Trailer) > 0 {
+		merged := *st
+		merged.Metadata = st.Metadata.Copy()
+		for k, v := range r.fault.Trailer {
+			merged.Metadata.Set(k, v...)
+		}
+		st = &merged
+	}
+	r.responder.writeStatus(st)
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package grpcwebtest provides an in-process server for testing
+// grpcweb clients without deploying a gateway or proxy. The server
+// speaks both the gRPC-gateway streaming protocol used by
+// GatewayClientBase and the gRPC-Web protocol used by GRPCWebClientBase.
+// Calls are served by Handlers or a *grpc.Server, and faults can
+// be injected into calls to verify the behavior of clients.
+package grpcwebtest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+// Handler serves a call. req is the serialized request message.
+// Responses are sent with the Send method of the Stream. The call
+// ends with the status described by the returned error: Ok if it is
+// nil, the code and message of a *grpcweb.Error, or Unknown otherwise.
+type Handler func(req []byte, stream *Stream) error
+
+// Fault describes misbehavior injected into calls.
+type Fault struct {
+	// Delay is waited before the call is served.
+	Delay time.Duration
+	// MessageDelay is waited before each response message is sent.
+	MessageDelay time.Duration
+	// Status, if set, ends the call with the Status
+	// instead of serving it.
+	Status *grpcweb.Status
+	// Trailer is added to the trailers of the call.
+	Trailer grpcweb.Metadata
+	// Abort, if set, aborts the connection once AbortAfter
+	// response messages have been sent, before the status.
+	Abort      bool
+	AbortAfter int
+	// Calls is the number of calls the Fault is injected into.
+	// A value of 0 injects it into all calls.
+	Calls int
+}
+
+// Server is an in-process server for testing grpcweb clients.
+// Its embedded httptest.Server is started by NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	faults   map[string][]*Fault
+	grpc     *grpc.Server
+
+	// compressor is the name of the Compressor
+	// response messages are compressed with.
+	compressor string
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithGRPCServer serves calls for which no Handler is
+// registered with the provided *grpc.Server.
+func WithGRPCServer(s *grpc.Server) Option {
+	return func(srv *Server) {
+		srv.grpc = s
+	}
+}
+
+// WithCompressor compresses the response messages of gRPC-Web calls
+// with the grpcweb Compressor registered with the name, if the client
+// lists it in its grpc-accept-encoding header.
+func WithCompressor(name string) Option {
+	return func(srv *Server) {
+		srv.compressor = name
+	}
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		handlers: map[string]Handler{},
+		faults:   map[string][]*Fault{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(s)
+
+	return s
+}
+
+// Handle registers the Handler for the method, in
+// the form "/package.Service/Method".
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	s.handlers[method] = h
+	s.mu.Unlock()
+}
+
+// Endpoint returns the endpoint clients use to call the method.
+func (s *Server) Endpoint(method string) string {
+	return s.URL + method
+}
+
+// InjectFault injects the Fault into subsequent calls of the
+// method. If method is empty, it is injected into calls of all
+// methods. Faults are applied in the order they were injected,
+// a single Fault per call.
+func (s *Server) InjectFault(method string, f Fault) {
+	s.mu.Lock()
+	s.faults[method] = append(s.faults[method], &f)
+	s.mu.Unlock()
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	s.faults = map[string][]*Fault{}
+	s.mu.Unlock()
+}
+
+// fault returns the Fault to inject into a call of the method, if any.
+func (s *Server) fault(method string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range []string{method, ""} {
+		faults := s.faults[m]
+		if len(faults) == 0 {
+			continue
+		}
+
+		f := *faults[0]
+		if faults[0].Calls > 0 {
+			faults[0].Calls--
+			if faults[0].Calls == 0 {
+				s.faults[m] = faults[1:]
+			}
+		}
+
+		return &f
+	}
+
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var resp responder
+	var req []byte
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web"):
+		text := strings.HasPrefix(contentType, "application/grpc-web-text")
+		if text {
+			if body, err = base64.StdEncoding.DecodeString(string(body)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if len(body) < 5 || len(body) < 5+int(binary.BigEndian.Uint32(body[1:5])) {
+			http.Error(w, "malformed request frame", http.StatusBadRequest)
+			return
+		}
+		req = body[5 : 5+binary.BigEndian.Uint32(body[1:5])]
+		resp = &grpcWebResponder{w: w, text: text, compressor: s.responseCompressor(r)}
+		if body[0]&compressedFlag != 0 {
+			var st *grpcweb.Status
+			if req, st = decompress(r.Header.Get(encodingHeader), req); st != nil {
+				resp.writeStatus(st)
+				return
+			}
+		}
+	case strings.HasPrefix(contentType, "application/x-protobuf"):
+		req = body
+		resp = &gatewayResponder{
+			w:      w,
+			base64: r.Header.Get("X-Accept-Content-Transfer-Encoding") == "base64",
+		}
+	default:
+		http.Error(w, "unsupported content type "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	f := s.fault(r.URL.Path)
+	fr := &faultResponder{responder: resp, fault: f}
+	if f != nil {
+		time.Sleep(f.Delay)
+		if f.Status != nil {
+			fr.writeStatus(f.Status)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	h, ok := s.handlers[r.URL.Path]
+	s.mu.Unlock()
+
+	switch {
+	case ok:
+		stream := &Stream{ctx: r.Context(), Header: r.Header, resp: fr}
+		stream.finish(h(req, stream))
+	case s.grpc != nil:
+		serveGRPC(s.grpc, fr, r, req)
+	default:
+		fr.writeStatus(&grpcweb.Status{Code: grpcweb.Unimplemented, Message: "unknown method " + r.URL.Path})
+	}
+
+	if fr.aborted {
+		// Closes the connection without ending the response
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// responseCompressor returns the Compressor configured with
+// WithCompressor, if the client r accepts it, or nil.
+func (s *Server) responseCompressor(r *http.Request) grpcweb.Compressor {
+	if s.compressor == "" {
+		return nil
+	}
+	for _, v := range r.Header.Values(acceptEncodingHeader) {
+		for _, name := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), s.compressor) {
+				return grpcweb.GetCompressor(s.compressor)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Stream sends the responses of a call served by a Handler.
+type Stream struct {
+	// Header holds the headers of the request.
+	Header http.Header
+
+	ctx     context.Context
+	resp    responder
+	header  grpcweb.Metadata
+	trailer grpcweb.Metadata
+}
+
+// Context returns the context of the request.
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// SetHeader adds md to the response headers. It
+// has no effect once a message has been sent.
+func (s *Stream) SetHeader(md grpcweb.Metadata) {
+	if s.header == nil {
+		s.header = grpcweb.Metadata{}
+	}
+	for k, v := range md {
+		s.header.Append(k, v...)
+	}
+}
+
+// SetTrailer adds md to the trailers of the call.
+func (s *Stream) SetTrailer(md grpcweb.Metadata) {
+	if s.trailer == nil {
+		s.trailer = grpcweb.Metadata{}
+	}
+	for k, v := range md {
+		s.trailer.Append(k, v...)
+	}
+}
+
+// Send sends a serialized response message.
+func (s *Stream) Send(msg []byte) error {
+	s.resp.writeHeader(s.header)
+	return s.resp.writeMessage(msg)
+}
+
+// finish ends the call with the status described by err.
+func (s *Stream) finish(err error) {
+	s.resp.writeHeader(s.header)
+	var e *grpcweb.Error
+	switch {
+	case err == nil:
+		s.resp.writeStatus(&grpcweb.Status{Code: grpcweb.Ok, Metadata: s.trailer})
+	case errors.As(err, &e):
+		st := &grpcweb.Status{Code: e.Code, Message: e.Message, Metadata: s.trailer}
+		s.resp.writeStatus(st.WithDetails(e.Details...))
+	default:
+		s.resp.writeStatus(&grpcweb.Status{Code: grpcweb.Unknown, Message: err.Error(), Metadata: s.trailer})
+	}
+}
@@ -0,0 +1,561 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcwebtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcmd "google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+const method = "/test.Service/Method"
+
+// frame is a decoded gRPC-Web frame
+type frame struct {
+	flags   byte
+	payload []byte
+}
+
+// post sends body to the method and returns the response and
+// the body read from it, which is truncated if err is not nil.
+func post(t *testing.T, s *Server, contentType string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, s.Endpoint(method), bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	return resp, respBody, err
+}
+
+// requestFrame returns msg as an uncompressed gRPC-Web frame
+func requestFrame(msg []byte) []byte {
+	b := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:5], uint32(len(msg)))
+	copy(b[5:], msg)
+	return b
+}
+
+// decodeBase64 decodes a sequence of padded base64 chunks.
+// Each 4 byte quantum is decoded separately, since the
+// padding of a chunk may be followed by another chunk.
+func decodeBase64(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var out []byte
+	for len(data) >= 4 {
+		b, err := base64.StdEncoding.DecodeString(string(data[:4]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b...)
+		data = data[4:]
+	}
+	if len(data) > 0 {
+		t.Fatalf("trailing base64 data %q", data)
+	}
+
+	return out
+}
+
+// readFrames decodes the gRPC-Web frames in body.
+// A truncated trailing frame is ignored.
+func readFrames(body []byte) []frame {
+	var frames []frame
+	for len(body) >= 5 {
+		length := int(binary.BigEndian.Uint32(body[1:5]))
+		if len(body) < 5+length {
+			break
+		}
+		frames = append(frames, frame{flags: body[0], payload: body[5 : 5+length]})
+		body = body[5+length:]
+	}
+
+	return frames
+}
+
+// trailers parses the header block of a trailer frame
+func trailers(t *testing.T, f frame) map[string]string {
+	t.Helper()
+
+	if f.flags&0x80 == 0 {
+		t.Fatalf("frame with flags %#x is not a trailer frame", f.flags)
+	}
+	md := map[string]string{}
+	for _, line := range strings.Split(string(f.payload), "\r\n") {
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 {
+			t.Fatalf("malformed trailer line %q", line)
+		}
+		md[kv[0]] = kv[1]
+	}
+
+	return md
+}
+
+// gatewayFields decodes the fields of a gateway response body
+func gatewayFields(t *testing.T, body []byte) []frame {
+	t.Helper()
+
+	var fields []frame
+	b := wire.NewBuffer(body)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != wire.Bytes {
+			t.Fatalf("field %d has wire type %d", field, typ)
+		}
+		payload, err := b.DecodeBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, frame{flags: byte(field), payload: payload})
+	}
+
+	return fields
+}
+
+func echoHandler(req []byte, stream *Stream) error {
+	stream.SetHeader(grpcweb.Metadata{"x-header": {"header value"}})
+	stream.SetTrailer(grpcweb.Metadata{"x-trailer": {"trailer value"}})
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestGRPCWebFraming(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+
+	for _, contentType := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		t.Run(contentType, func(t *testing.T) {
+			text := contentType == "application/grpc-web-text"
+			body := requestFrame([]byte("hello"))
+			if text {
+				body = []byte(base64.StdEncoding.EncodeToString(body))
+			}
+
+			resp, respBody, err := post(t, s, contentType, nil, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.Header.Get("Content-Type"); got != contentType {
+				t.Errorf("got Content-Type %q, want %q", got, contentType)
+			}
+			if got := resp.Header.Get("X-Header"); got != "header value" {
+				t.Errorf("got header %q, want %q", got, "header value")
+			}
+			if text {
+				respBody = decodeBase64(t, respBody)
+			}
+
+			frames := readFrames(respBody)
+			if len(frames) != 3 {
+				t.Fatalf("got %d frames, want 3", len(frames))
+			}
+			for _, f := range frames[:2] {
+				if f.flags != 0 || string(f.payload) != "hello" {
+					t.Errorf("got message frame %#x %q, want 0x0 %q", f.flags, f.payload, "hello")
+				}
+			}
+			md := trailers(t, frames[2])
+			if md["grpc-status"] != "0" {
+				t.Errorf("got grpc-status %q, want 0", md["grpc-status"])
+			}
+			if md["x-trailer"] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", md["x-trailer"], "trailer value")
+			}
+		})
+	}
+}
+
+func TestGatewayFraming(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+
+	for _, b64 := range []bool{false, true} {
+		name := "binary"
+		header := http.Header{}
+		if b64 {
+			name = "base64"
+			header.Set("X-Accept-Content-Transfer-Encoding", "base64")
+		}
+		t.Run(name, func(t *testing.T) {
+			resp, body, err := post(t, s, "application/x-protobuf", header, []byte("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b64 {
+				if got := resp.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+					t.Errorf("got Content-Transfer-Encoding %q, want base64", got)
+				}
+				body = decodeBase64(t, body)
+			}
+
+			fields := gatewayFields(t, body)
+			if len(fields) != 3 {
+				t.Fatalf("got %d fields, want 3", len(fields))
+			}
+			for _, f := range fields[:2] {
+				if f.flags != 1 || string(f.payload) != "hello" {
+					t.Errorf("got field %d %q, want 1 %q", f.flags, f.payload, "hello")
+				}
+			}
+			if fields[2].flags != 2 {
+				t.Fatalf("got field %d, want the status in field 2", fields[2].flags)
+			}
+			st, err := grpcweb.ParseRPCStatus(fields[2].payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Code != grpcweb.Ok {
+				t.Errorf("got code %v, want Ok", st.Code)
+			}
+			if got := st.Metadata.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	for _, tt := range []struct {
+		name    string
+		err     error
+		status  string
+		message string
+	}{
+		{"grpcweb error", &grpcweb.Error{Code: grpcweb.NotFound, Message: "no such thing"}, "5", "no%20such%20thing"},
+		{"other error", errors.New("boom"), "2", "boom"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s.Handle(method, func([]byte, *Stream) error { return tt.err })
+
+			_, body, err := post(t, s, "application/grpc-web+proto", nil, requestFrame(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			frames := readFrames(body)
+			if len(frames) != 1 {
+				t.Fatalf("got %d frames, want a trailers-only response", len(frames))
+			}
+			md := trailers(t, frames[0])
+			if md["grpc-status"] != tt.status || md["grpc-message"] != tt.message {
+				t.Errorf("got status %q %q, want %q %q", md["grpc-status"], md["grpc-message"], tt.status, tt.message)
+			}
+		})
+	}
+}
+
+func TestUnimplemented(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	_, body, err := post(t, s, "application/grpc-web+proto", nil, requestFrame(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := readFrames(body)
+	if len(frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(frames))
+	}
+	if got := trailers(t, frames[0])["grpc-status"]; got != "12" {
+		t.Errorf("got grpc-status %q, want 12", got)
+	}
+}
+
+func TestMalformedRequests(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		body        []byte
+		code        int
+	}{
+		{"truncated frame", "application/grpc-web+proto", []byte{0, 0, 0, 0, 9, 'a'}, http.StatusBadRequest},
+		{"short frame", "application/grpc-web+proto", []byte{0, 0}, http.StatusBadRequest},
+		{"malformed base64", "application/grpc-web-text", []byte("!!!"), http.StatusBadRequest},
+		{"unknown content type", "text/plain", nil, http.StatusUnsupportedMediaType},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _, err := post(t, s, tt.contentType, nil, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.code {
+				t.Errorf("got HTTP status %d, want %d", resp.StatusCode, tt.code)
+			}
+		})
+	}
+
+	resp, err := s.Client().Get(s.Endpoint(method))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got HTTP status %d for GET, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestFaultStatus(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+	s.InjectFault(method, Fault{
+		Status: &grpcweb.Status{Code: grpcweb.Unavailable, Message: "try again"},
+		Calls:  1,
+	})
+
+	for i, want := range []string{"14", "0"} {
+		_, body, err := post(t, s, "application/grpc-web+proto", nil, requestFrame([]byte("hello")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames := readFrames(body)
+		if got := trailers(t, frames[len(frames)-1])["grpc-status"]; got != want {
+			t.Errorf("call %d: got grpc-status %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestFaultTrailer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+	s.InjectFault("", Fault{Trailer: grpcweb.Metadata{"x-fault": {"injected"}}})
+
+	_, body, err := post(t, s, "application/grpc-web+proto", nil, requestFrame([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := readFrames(body)
+	md := trailers(t, frames[len(frames)-1])
+	if md["x-fault"] != "injected" || md["x-trailer"] != "trailer value" {
+		t.Errorf("got trailers %v, want the injected and the handler trailer", md)
+	}
+
+	s.ClearFaults()
+	_, body, err = post(t, s, "application/grpc-web+proto", nil, requestFrame([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames = readFrames(body)
+	if _, ok := trailers(t, frames[len(frames)-1])["x-fault"]; ok {
+		t.Error("got the injected trailer after ClearFaults")
+	}
+}
+
+func TestFaultAbort(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+	s.InjectFault(method, Fault{Abort: true, AbortAfter: 1})
+
+	_, body, err := post(t, s, "application/grpc-web+proto", nil, requestFrame([]byte("hello")))
+	if err == nil {
+		t.Error("reading an aborted response succeeded")
+	}
+	frames := readFrames(body)
+	if len(frames) != 1 || frames[0].flags != 0 {
+		t.Errorf("got frames %v, want a single message frame", frames)
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Handle(method, echoHandler)
+	const delay, messageDelay = 50 * time.Millisecond, 20 * time.Millisecond
+	s.InjectFault(method, Fault{Delay: delay, MessageDelay: messageDelay})
+
+	start := time.Now()
+	if _, _, err := post(t, s, "application/grpc-web+proto", nil, requestFrame([]byte("hello"))); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, want := time.Since(start), delay+2*messageDelay; elapsed < want {
+		t.Errorf("call took %v, want at least %v", elapsed, want)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	s := NewServer(WithCompressor("gzip"))
+	defer s.Close()
+	var got []byte
+	s.Handle(method, func(req []byte, stream *Stream) error {
+		got = req
+		return stream.Send(req)
+	})
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	body := requestFrame(compressed.Bytes())
+	body[0] = compressedFlag
+	header := http.Header{}
+	header.Set(encodingHeader, "gzip")
+	header.Set(acceptEncodingHeader, "identity, gzip")
+
+	resp, respBody, err := post(t, s, "application/grpc-web+proto", header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("handler got request %q, want %q", got, "hello")
+	}
+	if enc := resp.Header.Get(encodingHeader); enc != "gzip" {
+		t.Errorf("got %s %q, want gzip", encodingHeader, enc)
+	}
+
+	frames := readFrames(respBody)
+	if len(frames) != 2 || frames[0].flags != compressedFlag {
+		t.Fatalf("got frames %v, want a compressed message frame and a trailer frame", frames)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(frames[0].payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "hello" {
+		t.Errorf("got response %q, want %q", msg, "hello")
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	gs := grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		in := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(in); err != nil {
+			return err
+		}
+		stream.SetHeader(grpcmd.Pairs("x-header", "header value"))
+		stream.SetTrailer(grpcmd.Pairs("x-trailer", "trailer value"))
+		if in.Value == "fail" {
+			return grpcstatus.Error(codes.PermissionDenied, "not 100% allowed")
+		}
+		for i := 0; i < 2; i++ {
+			if err := stream.SendMsg(wrapperspb.String("echo " + in.Value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	s := NewServer(WithGRPCServer(gs))
+	defer s.Close()
+
+	request := func(t *testing.T, v string) (*http.Response, []frame) {
+		req, err := proto.Marshal(wrapperspb.String(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, body, err := post(t, s, "application/grpc-web+proto", nil, requestFrame(req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, readFrames(body)
+	}
+
+	t.Run("messages", func(t *testing.T) {
+		resp, frames := request(t, "hello")
+		if got := resp.Header.Get("X-Header"); got != "header value" {
+			t.Errorf("got header %q, want %q", got, "header value")
+		}
+		if len(frames) != 3 {
+			t.Fatalf("got %d frames, want 3", len(frames))
+		}
+		for _, f := range frames[:2] {
+			out := &wrapperspb.StringValue{}
+			if err := proto.Unmarshal(f.payload, out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Value != "echo hello" {
+				t.Errorf("got response %q, want %q", out.Value, "echo hello")
+			}
+		}
+		md := trailers(t, frames[2])
+		if md["grpc-status"] != "0" || md["x-trailer"] != "trailer value" {
+			t.Errorf("got trailers %v, want status 0 and the trailer", md)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, frames := request(t, "fail")
+		if len(frames) != 1 {
+			t.Fatalf("got %d frames, want 1", len(frames))
+		}
+		md := trailers(t, frames[0])
+		if md["grpc-status"] != "7" || md["grpc-message"] != "not%20100%25%20allowed" {
+			t.Errorf("got status %q %q, want 7 %q", md["grpc-status"], md["grpc-message"], "not%20100%25%20allowed")
+		}
+		if md["x-trailer"] != "trailer value" {
+			t.Errorf("got trailer %q, want %q", md["x-trailer"], "trailer value")
+		}
+	})
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build !js
+// +build !js
+
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// httpTransport is a Transport using net/http,
+// speaking the gRPC-Web protocol.
+type httpTransport struct {
+	client *http.Client
+}
+
+// NewHTTPTransport returns a Transport that sends requests with
+// the provided http.Client, using the gRPC-Web protocol. If client
+// is nil, http.DefaultClient is used. It is only available outside
+// of the browser, where it lets the client run under go test.
+func NewHTTPTransport(client *http.Client) Transport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return httpTransport{client: client}
+}
+
+// defaultGRPCWebTransport returns the Transport
+// used by GRPCWebClientBase unless configured otherwise.
+func defaultGRPCWebTransport() Transport {
+	return NewHTTPTransport(nil)
+}
+
+// NewStream implements Transport.
+func (t httpTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	contentType, body, err := encodeBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if req.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), req.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	hreq, err := http.NewRequest(http.MethodPost, req.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, &Error{Code: Internal, Message: err.Error(), Err: err}
+	}
+	hreq = hreq.WithContext(ctx)
+
+	headerValues(req.Header, hreq.Header.Add)
+	hreq.Header.Set("Content-Type", contentType)
+	hreq.Header.Set("Accept", contentType)
+	hreq.Header.Set("X-Grpc-Web", "1")
+	compressionHeaders(req.Compressor, hreq.Header.Set)
+	if req.Timeout > 0 {
+		hreq.Header.Set("grpc-timeout", encodeTimeout(req.Timeout))
+	}
+
+	resp, err := t.client.Do(hreq)
+	if err != nil {
+		err = httpError(ctx, err)
+		cancel()
+		return nil, err
+	}
+
+	header := Metadata{}
+	for k, v := range resp.Header {
+		// Malformed binary values are dropped
+		_ = header.AppendHeader(k, v...)
+	}
+
+	return &httpStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		resp:    resp,
+		header:  header,
+		text:    isTextContentType(headerValue(header, "content-type")),
+		decoder: frameDecoder{maxMsgSize: req.MaxRecvMsgSize},
+	}, nil
+}
+
+// httpStream is the TransportStream of a httpTransport.
+type httpStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	resp   *http.Response
+	header Metadata
+	eof    bool
+
+	text    bool
+	textDec base64Decoder
+	decoder frameDecoder
+	pending []*Frame
+	buf     [4096]byte
+
+	// err ends the stream once the pending frames are read
+	err error
+}
+
+func (s *httpStream) Header() Metadata {
+	return s.header
+}
+
+// Recv only reads from the response body when no
+// decoded frames are pending, applying backpressure
+// to the server when the client is slow.
+func (s *httpStream) Recv() (*Frame, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		if s.eof {
+			if err := s.end(); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		n, err := s.resp.Body.Read(s.buf[:])
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			err = httpError(s.ctx, err)
+			s.Close()
+			return nil, err
+		}
+
+		chunk := s.buf[:n]
+		if s.text {
+			chunk, err = s.textDec.decode(chunk)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+
+		s.pending, s.err = s.decoder.decode(chunk)
+		if s.err != nil {
+			s.Close()
+		}
+		if len(s.pending) > 0 && s.pending[len(s.pending)-1].Status != nil {
+			// No frames follow the trailer frame
+			s.Close()
+		}
+	}
+
+	f := s.pending[0]
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+
+	return f, nil
+}
+
+// end returns the Status frame of a response that
+// ended without a trailer frame, or the error describing why it ended.
+func (s *httpStream) end() error {
+	defer s.Close()
+
+	// Trailers-only responses carry the status in the headers
+	if status, ok := statusFromMetadata(s.header); ok {
+		s.pending = append(s.pending, &Frame{Status: status})
+		return nil
+	}
+
+	if s.resp.StatusCode != http.StatusOK {
+		return &Error{Code: FromHTTPStatus(s.resp.StatusCode), Message: s.resp.Status}
+	}
+
+	return &Error{Code: Internal, Message: "response ended without a status"}
+}
+
+func (s *httpStream) Close() {
+	s.cancel()
+	s.resp.Body.Close()
+}
+
+// httpError converts a failed request or read into an Error.
+func httpError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &Error{Code: DeadlineExceeded, Message: "request timed out", Err: err}
+	case errors.Is(ctx.Err(), context.Canceled):
+		return &Error{Code: Cancelled, Message: "stream cancelled", Err: err}
+	default:
+		return &Error{Code: Unavailable, Message: err.Error(), Err: err}
+	}
+}
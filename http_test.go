@@ -0,0 +1,326 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build !js
+// +build !js
+
+package grpcweb_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/grpcwebtest"
+)
+
+func TestHTTPTransportRPCCall(t *testing.T) {
+	s := grpcwebtest.NewServer()
+	defer s.Close()
+	s.Handle(method, func(req []byte, stream *grpcwebtest.Stream) error {
+		stream.SetHeader(grpcweb.Metadata{"x-header": {stream.Header.Get("X-Metadata")}})
+		stream.SetTrailer(grpcweb.Metadata{"x-trailer": {"trailer value"}})
+		return stream.Send(append([]byte("echo "), req...))
+	})
+
+	for name, c := range newClients(grpcweb.NewHTTPTransport(s.Client())) {
+		t.Run(name, func(t *testing.T) {
+			var header, trailer grpcweb.Metadata
+			resp, err := c.RPCCall(s.Endpoint(method), message("hello"),
+				grpcweb.WithMetadata(grpcweb.Metadata{"x-metadata": {"metadata value"}}),
+				grpcweb.Header(&header),
+				grpcweb.Trailer(&trailer),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(resp) != "echo hello" {
+				t.Errorf("got response %q, want %q", resp, "echo hello")
+			}
+			if got := header.Get("x-header"); len(got) != 1 || got[0] != "metadata value" {
+				t.Errorf("got header %q, want the metadata sent with WithMetadata", got)
+			}
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+func TestHTTPTransportMetadataProtocolHeaders(t *testing.T) {
+	s := grpcwebtest.NewServer()
+	defer s.Close()
+	s.Handle(method, func(req []byte, stream *grpcwebtest.Stream) error {
+		return stream.Send(req)
+	})
+
+	// Metadata must not replace the headers of the protocol
+	c := newClients(grpcweb.NewHTTPTransport(s.Client()))["binary"]
+	resp, err := c.RPCCall(s.Endpoint(method), message("hello"), grpcweb.WithMetadata(grpcweb.Metadata{
+		"content-type": {"text/plain"},
+		"x-grpc-web":   {"0"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "hello" {
+		t.Errorf("got response %q, want %q", resp, "hello")
+	}
+}
+
+func TestHTTPTransportServerStreaming(t *testing.T) {
+	s := grpcwebtest.NewServer()
+	defer s.Close()
+	want := []string{"one", "two", "three"}
+	s.Handle(method, func(req []byte, stream *grpcwebtest.Stream) error {
+		for _, msg := range want {
+			if err := stream.Send([]byte(msg)); err != nil {
+				return err
+			}
+		}
+		stream.SetTrailer(grpcweb.Metadata{"x-trailer": {"trailer value"}})
+		return nil
+	})
+
+	for name, c := range newClients(grpcweb.NewHTTPTransport(s.Client())) {
+		t.Run(name, func(t *testing.T) {
+			var trailer grpcweb.Metadata
+			stream, err := c.ServerStreaming(s.Endpoint(method), message("hello"), grpcweb.Trailer(&trailer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			for _, w := range want {
+				msg, err := stream.Recv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(msg) != w {
+					t.Errorf("got message %q, want %q", msg, w)
+				}
+			}
+			if _, err := stream.Recv(); err != io.EOF {
+				t.Fatalf("got %v at the end of the stream, want io.EOF", err)
+			}
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+func TestHTTPTransportErrors(t *testing.T) {
+	s := grpcwebtest.NewServer()
+	defer s.Close()
+	detail := grpcweb.Any{TypeURL: "type.googleapis.com/test.Detail", Value: []byte{0x08, 0x01}}
+	s.Handle(method, func(req []byte, stream *grpcwebtest.Stream) error {
+		if string(req) == "stream" {
+			if err := stream.Send([]byte("first")); err != nil {
+				return err
+			}
+		}
+		stream.SetTrailer(grpcweb.Metadata{"x-trailer": {"trailer value"}})
+		return &grpcweb.Error{Code: grpcweb.NotFound, Message: "no such thing", Details: []grpcweb.Any{detail}}
+	})
+
+	checkErr := func(t *testing.T, err error) {
+		t.Helper()
+		var e *grpcweb.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("got error %v, want a *grpcweb.Error", err)
+		}
+		if e.Code != grpcweb.NotFound || e.Message != "no such thing" {
+			t.Errorf("got error %v %q, want NotFound %q", e.Code, e.Message, "no such thing")
+		}
+		if len(e.Details) != 1 || e.Details[0].TypeURL != detail.TypeURL || string(e.Details[0].Value) != string(detail.Value) {
+			t.Errorf("got details %v, want %v", e.Details, detail)
+		}
+		if !errors.Is(err, grpcweb.ErrCode(grpcweb.NotFound)) {
+			t.Error("errors.Is(err, ErrCode(NotFound)) = false")
+		}
+	}
+
+	for name, c := range newClients(grpcweb.NewHTTPTransport(s.Client())) {
+		t.Run(name, func(t *testing.T) {
+			var trailer grpcweb.Metadata
+			_, err := c.RPCCall(s.Endpoint(method), message("unary"), grpcweb.Trailer(&trailer))
+			checkErr(t, err)
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+
+			stream, err := c.ServerStreaming(s.Endpoint(method), message("stream"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			if msg, err := stream.Recv(); err != nil || string(msg) != "first" {
+				t.Fatalf("got %q, %v, want the message sent before the error", msg, err)
+			}
+			_, err = stream.Recv()
+			checkErr(t, err)
+		})
+	}
+}
+
+func TestHTTPTransportFaults(t *testing.T) {
+	s := grpcwebtest.NewServer()
+	defer s.Close()
+	s.Handle(method, func(req []byte, stream *grpcwebtest.Stream) error {
+		for i := 0; i < 3; i++ {
+			if err := stream.Send(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	c := newClients(grpcweb.NewHTTPTransport(s.Client()))["binary"]
+
+	s.InjectFault(method, grpcwebtest.Fault{
+		Status: &grpcweb.Status{Code: grpcweb.Unavailable, Message: "try again"},
+		Calls:  1,
+	})
+	if _, err := c.RPCCall(s.Endpoint(method), message("hello")); !errors.Is(err, grpcweb.ErrCode(grpcweb.Unavailable)) {
+		t.Errorf("got %v, want the injected Unavailable status", err)
+	}
+
+	s.InjectFault(method, grpcwebtest.Fault{Abort: true, AbortAfter: 1, Calls: 1})
+	stream, err := c.ServerStreaming(s.Endpoint(method), message("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("got %v, want the message sent before the abort", err)
+	}
+	if _, err := stream.Recv(); err == nil || err == io.EOF {
+		t.Errorf("got %v after the connection was aborted, want an error", err)
+	}
+}
+
+func TestHTTPTransportTrailersOnly(t *testing.T) {
+	// A trailers-only response carries the status in the
+	// HTTP headers of a response without a body.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		w.Header().Set("grpc-status", "7")
+		w.Header().Set("grpc-message", "not%20allowed")
+		w.Header().Set("x-trailer", "trailer value")
+	}))
+	defer s.Close()
+
+	for name, c := range newClients(grpcweb.NewHTTPTransport(s.Client())) {
+		t.Run(name, func(t *testing.T) {
+			var trailer grpcweb.Metadata
+			_, err := c.RPCCall(s.URL+method, message("hello"), grpcweb.Trailer(&trailer))
+			var e *grpcweb.Error
+			if !errors.As(err, &e) || e.Code != grpcweb.PermissionDenied || e.Message != "not allowed" {
+				t.Fatalf("got error %v, want PermissionDenied %q", err, "not allowed")
+			}
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+func TestHTTPTransportTimeout(t *testing.T) {
+	timeouts := make(chan string, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeouts <- r.Header.Get("grpc-timeout")
+		// The server notices the client going away
+		// once the request body has been read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	c := newClients(grpcweb.NewHTTPTransport(s.Client()))["binary"]
+	_, err := c.RPCCall(s.URL+method, message("hello"), grpcweb.WithTimeout(1500*time.Microsecond))
+	if !errors.Is(err, grpcweb.ErrCode(grpcweb.DeadlineExceeded)) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	// The timeout is rounded up to whole milliseconds
+	if got := <-timeouts; got != "2m" {
+		t.Errorf("got grpc-timeout %q, want %q", got, "2m")
+	}
+}
+
+func TestHTTPTransportHTTPStatus(t *testing.T) {
+	for _, tt := range []struct {
+		httpStatus int
+		code       grpcweb.StatusCode
+	}{
+		{http.StatusBadRequest, grpcweb.Internal},
+		{http.StatusUnauthorized, grpcweb.Unauthenticated},
+		{http.StatusForbidden, grpcweb.PermissionDenied},
+		{http.StatusNotFound, grpcweb.Unimplemented},
+		{http.StatusTooManyRequests, grpcweb.Unavailable},
+		{http.StatusBadGateway, grpcweb.Unavailable},
+		{http.StatusServiceUnavailable, grpcweb.Unavailable},
+		{http.StatusGatewayTimeout, grpcweb.Unavailable},
+		{http.StatusInternalServerError, grpcweb.Unknown},
+	} {
+		t.Run(http.StatusText(tt.httpStatus), func(t *testing.T) {
+			if got := grpcweb.FromHTTPStatus(tt.httpStatus); got != tt.code {
+				t.Errorf("FromHTTPStatus(%d) = %v, want %v", tt.httpStatus, got, tt.code)
+			}
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.httpStatus)
+			}))
+			defer s.Close()
+
+			c := newClients(grpcweb.NewHTTPTransport(s.Client()))["binary"]
+			_, err := c.RPCCall(s.URL+method, message("hello"))
+			if !errors.Is(err, grpcweb.ErrCode(tt.code)) {
+				t.Errorf("got %v, want %v", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestHTTPTransportMaxRecvMsgSize(t *testing.T) {
+	s := grpcwebtest.NewServer()
+	defer s.Close()
+	large := make([]byte, grpcweb.DefaultMaxRecvMsgSize+1)
+	s.Handle(method, func(req []byte, stream *grpcwebtest.Stream) error {
+		return stream.Send(large)
+	})
+	c := newClients(grpcweb.NewHTTPTransport(s.Client()))["binary"]
+
+	if _, err := c.RPCCall(s.Endpoint(method), message("hello")); !errors.Is(err, grpcweb.ErrCode(grpcweb.ResourceExhausted)) {
+		t.Errorf("got %v with the default limit, want ResourceExhausted", err)
+	}
+
+	// A limit of 0 means no limit
+	resp, err := c.RPCCall(s.Endpoint(method), message("hello"), grpcweb.MaxCallRecvMsgSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != len(large) {
+		t.Errorf("got %d bytes, want %d", len(resp), len(large))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+// UnaryInvoker is called by a UnaryClientInterceptor to complete a unary call.
+type UnaryInvoker func(method string, req ProtoMessage, opts ...CallOption) ([]byte, error)
+
+// UnaryClientInterceptor intercepts the execution of a unary call.
+// It may inspect or modify the method, request and call options before
+// calling invoker, and inspect or translate the response and error
+// returned by it.
+type UnaryClientInterceptor func(method string, req ProtoMessage, invoker UnaryInvoker, opts ...CallOption) ([]byte, error)
+
+// Streamer is called by a StreamClientInterceptor to create a ClientStream.
+type Streamer func(method string, req ProtoMessage, opts ...CallOption) (ClientStream, error)
+
+// StreamClientInterceptor intercepts the creation of a stream.
+// It may wrap the ClientStream returned by streamer to intercept
+// the messages and errors received on it. For client and bidirectional
+// streams, req is nil and messages are sent on the stream returned by
+// streamer, which implements BidiStream.
+type StreamClientInterceptor func(method string, req ProtoMessage, streamer Streamer, opts ...CallOption) (ClientStream, error)
+
+// chainUnaryInterceptors combines the interceptors into a single
+// interceptor. The first interceptor is the outermost one.
+func chainUnaryInterceptors(interceptors []UnaryClientInterceptor) UnaryClientInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(method string, req ProtoMessage, invoker UnaryInvoker, opts ...CallOption) ([]byte, error) {
+		return interceptors[0](method, req, chainedUnaryInvoker(interceptors, 1, invoker), opts...)
+	}
+}
+
+func chainedUnaryInvoker(interceptors []UnaryClientInterceptor, i int, invoker UnaryInvoker) UnaryInvoker {
+	if i == len(interceptors) {
+		return invoker
+	}
+
+	return func(method string, req ProtoMessage, opts ...CallOption) ([]byte, error) {
+		return interceptors[i](method, req, chainedUnaryInvoker(interceptors, i+1, invoker), opts...)
+	}
+}
+
+// chainStreamInterceptors combines the interceptors into a single
+// interceptor. The first interceptor is the outermost one.
+func chainStreamInterceptors(interceptors []StreamClientInterceptor) StreamClientInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(method string, req ProtoMessage, streamer Streamer, opts ...CallOption) (ClientStream, error) {
+		return interceptors[0](method, req, chainedStreamer(interceptors, 1, streamer), opts...)
+	}
+}
+
+func chainedStreamer(interceptors []StreamClientInterceptor, i int, streamer Streamer) Streamer {
+	if i == len(interceptors) {
+		return streamer
+	}
+
+	return func(method string, req ProtoMessage, opts ...CallOption) (ClientStream, error) {
+		return interceptors[i](method, req, chainedStreamer(interceptors, i+1, streamer), opts...)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+// callLog records the order in which interceptors run
+type callLog []string
+
+func (l *callLog) unary(name string) grpcweb.UnaryClientInterceptor {
+	return func(method string, req grpcweb.ProtoMessage, invoker grpcweb.UnaryInvoker, opts ...grpcweb.CallOption) ([]byte, error) {
+		*l = append(*l, name+" before")
+		resp, err := invoker(method, req, opts...)
+		*l = append(*l, name+" after")
+		return resp, err
+	}
+}
+
+func (l *callLog) stream(name string) grpcweb.StreamClientInterceptor {
+	return func(method string, req grpcweb.ProtoMessage, streamer grpcweb.Streamer, opts ...grpcweb.CallOption) (grpcweb.ClientStream, error) {
+		*l = append(*l, name+" before")
+		cs, err := streamer(method, req, opts...)
+		*l = append(*l, name+" after")
+		if err != nil {
+			return nil, err
+		}
+		return &loggedStream{ClientStream: cs, log: l, name: name}, nil
+	}
+}
+
+func (l callLog) String() string {
+	return strings.Join(l, ", ")
+}
+
+// loggedStream records the messages received through an interceptor
+type loggedStream struct {
+	grpcweb.ClientStream
+	log  *callLog
+	name string
+}
+
+func (s *loggedStream) Recv() ([]byte, error) {
+	msg, err := s.ClientStream.Recv()
+	if err == nil {
+		*s.log = append(*s.log, s.name+" recv "+string(msg))
+	}
+	return msg, err
+}
+
+func TestUnaryInterceptorOrder(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+	var log callLog
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithUnaryInterceptor(log.unary("a"), log.unary("b")),
+		grpcweb.WithUnaryInterceptor(log.unary("c")),
+	)
+
+	resp, err := c.RPCCall(method, message("req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "resp" {
+		t.Errorf("got response %q, want %q", resp, "resp")
+	}
+	if want := "a before, b before, c before, c after, b after, a after"; log.String() != want {
+		t.Errorf("got interceptor calls %q, want %q", log, want)
+	}
+	if n := len(transport.Requests()); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestUnaryInterceptorShortCircuit(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	var log callLog
+	cached := func(method string, req grpcweb.ProtoMessage, invoker grpcweb.UnaryInvoker, opts ...grpcweb.CallOption) ([]byte, error) {
+		log = append(log, "cache hit")
+		return []byte("cached"), nil
+	}
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithUnaryInterceptor(log.unary("a"), cached, log.unary("c")),
+	)
+
+	resp, err := c.RPCCall(method, message("req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "cached" {
+		t.Errorf("got response %q, want %q", resp, "cached")
+	}
+	if want := "a before, cache hit, a after"; log.String() != want {
+		t.Errorf("got interceptor calls %q, want %q", log, want)
+	}
+	if n := len(transport.Requests()); n != 0 {
+		t.Errorf("got %d requests, want none", n)
+	}
+}
+
+func TestStreamInterceptorOrder(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("one")}})
+	var log callLog
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithStreamInterceptor(log.stream("a"), log.stream("b")),
+		grpcweb.WithStreamInterceptor(log.stream("c")),
+	)
+
+	for _, tt := range []struct {
+		name string
+		open func() (grpcweb.ClientStream, error)
+	}{
+		{"server stream", func() (grpcweb.ClientStream, error) {
+			return c.ServerStreaming(method, message("req"))
+		}},
+		{"bidi stream", func() (grpcweb.ClientStream, error) {
+			stream, err := c.BidiStreaming(method)
+			if err != nil {
+				return nil, err
+			}
+			return stream, stream.CloseSend()
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			log = nil
+			stream, err := tt.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			if msg, err := stream.Recv(); err != nil || string(msg) != "one" {
+				t.Fatalf("got %q, %v, want %q", msg, err, "one")
+			}
+
+			// The innermost wrapper receives the message first
+			want := "a before, b before, c before, c after, b after, a after, c recv one, b recv one, a recv one"
+			if log.String() != want {
+				t.Errorf("got interceptor calls %q, want %q", log, want)
+			}
+		})
+	}
+}
+
+func TestStreamInterceptorShortCircuit(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	var log callLog
+	errDenied := &grpcweb.Error{Code: grpcweb.PermissionDenied, Message: "denied by interceptor"}
+	deny := func(method string, req grpcweb.ProtoMessage, streamer grpcweb.Streamer, opts ...grpcweb.CallOption) (grpcweb.ClientStream, error) {
+		log = append(log, "deny")
+		return nil, errDenied
+	}
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithStreamInterceptor(log.stream("a"), deny, log.stream("c")),
+	)
+
+	if _, err := c.ServerStreaming(method, message("req")); !errors.Is(err, errDenied) {
+		t.Errorf("ServerStreaming returned %v, want the error of the interceptor", err)
+	}
+	if _, err := c.BidiStreaming(method); !errors.Is(err, errDenied) {
+		t.Errorf("BidiStreaming returned %v, want the error of the interceptor", err)
+	}
+	if want := "a before, deny, a after, a before, deny, a after"; log.String() != want {
+		t.Errorf("got interceptor calls %q, want %q", log, want)
+	}
+	if n := len(transport.Requests()); n != 0 {
+		t.Errorf("got %d requests, want none", n)
+	}
+}
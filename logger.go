@@ -0,0 +1,77 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Logger receives the diagnostics of a client. A *logging.Logger
+// implements it. Entries about calls are logged at FINE level,
+// and entries about individual messages at FINER level.
+type Logger interface {
+	Fine(message string)
+	Finer(message string)
+}
+
+// WithLogger sets the Logger used by the client. By default, clients
+// use the logging package logger "grpcweb" under GopherJS,
+// and discard all entries elsewhere.
+func WithLogger(l Logger) ClientOption {
+	return func(c *clientBase) {
+		c.logger = l
+	}
+}
+
+// nopLogger is a Logger discarding all entries.
+type nopLogger struct{}
+
+func (nopLogger) Fine(string)  {}
+func (nopLogger) Finer(string) {}
+
+// logEntry formats a log message followed by its context,
+// given as alternating keys and values.
+func logEntry(message string, context ...string) string {
+	var b strings.Builder
+	b.WriteString(message)
+	for i := 0; i+1 < len(context); i += 2 {
+		b.WriteString(" " + context[i] + "=" + context[i+1])
+	}
+
+	return b.String()
+}
+
+// errorCode returns the StatusCode of err.
+func errorCode(err error) StatusCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return Unknown
+}
+
+// elapsedField formats the time since start for a log entry.
+func elapsedField(start time.Time) string {
+	return time.Since(start).String()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build !js || wasm
+// +build !js wasm
+
+package grpcweb_test
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+// captureOutput returns what f writes to the standard
+// output and error and to the standard logger
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(os.Stderr)
+	}()
+
+	f()
+
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out) + logged.String()
+}
+
+func TestDefaultLogger(t *testing.T) {
+	logger := grpcweb.DefaultLogger()
+	out := captureOutput(t, func() {
+		logger.Fine("fine")
+		logger.Finer("finer")
+
+		transport := grpcweb.NewFakeTransport()
+		transport.Script(method, &grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.NotFound}})
+		c := grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport))
+		if _, err := c.RPCCall(method, message("req")); err == nil {
+			t.Error("call succeeded, want a NotFound error")
+		}
+	})
+	if out != "" {
+		t.Errorf("the default logger printed %q, want nothing", out)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+// recordLogger records the entries logged at each level
+type recordLogger struct {
+	mu    sync.Mutex
+	fine  []string
+	finer []string
+}
+
+func (l *recordLogger) Fine(message string) {
+	l.mu.Lock()
+	l.fine = append(l.fine, message)
+	l.mu.Unlock()
+}
+
+func (l *recordLogger) Finer(message string) {
+	l.mu.Lock()
+	l.finer = append(l.finer, message)
+	l.mu.Unlock()
+}
+
+// checkEntries checks that each entry starts with the prefix
+// of the same index, which holds the message and the fields
+// that don't depend on timing
+func checkEntries(t *testing.T, level string, entries []string, prefixes ...string) {
+	t.Helper()
+
+	if len(entries) != len(prefixes) {
+		t.Fatalf("got %s entries %q, want %d", level, entries, len(prefixes))
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(entries[i], prefix) {
+			t.Errorf("got %s entry %q, want it to start with %q", level, entries[i], prefix)
+		}
+	}
+}
+
+func TestLogEntry(t *testing.T) {
+	for _, tt := range []struct {
+		message string
+		context []string
+		want    string
+	}{
+		{"call finished", nil, "call finished"},
+		{"call finished", []string{"method", method}, "call finished method=" + method},
+		{"call failed", []string{"code", "NotFound", "error", "not found"}, "call failed code=NotFound error=not found"},
+		// A key without a value is dropped
+		{"call failed", []string{"code", "NotFound", "error"}, "call failed code=NotFound"},
+	} {
+		if got := grpcweb.LogEntry(tt.message, tt.context...); got != tt.want {
+			t.Errorf("logEntry(%q, %q) = %q, want %q", tt.message, tt.context, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}})
+	logger := &recordLogger{}
+	c := grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport), grpcweb.WithLogger(logger))
+
+	if _, err := c.RPCCall(method, message("request")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls are logged at FINE level, and messages at FINER level
+	checkEntries(t, "FINE", logger.fine,
+		"call finished method="+method+" code="+grpcweb.Ok.String()+" elapsed=")
+	checkEntries(t, "FINER", logger.finer,
+		"sending request method="+method+" bytes=",
+		"received message method="+method+" bytes=4")
+	if !strings.HasSuffix(logger.fine[0], " bytes=4") {
+		t.Errorf("got %q, want the received bytes last", logger.fine[0])
+	}
+}
+
+func TestLoggerFailedCall(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method,
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unavailable, Message: "overloaded"}},
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.NotFound, Message: "not found"}},
+	)
+	logger := &recordLogger{}
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithLogger(logger),
+		grpcweb.WithRetryPolicy(&grpcweb.RetryPolicy{
+			MaxAttempts:          2,
+			InitialBackoff:       time.Millisecond,
+			RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable},
+		}),
+	)
+
+	if _, err := c.RPCCall(method, message("req")); err == nil {
+		t.Fatal("call succeeded, want a NotFound error")
+	}
+
+	checkEntries(t, "FINE", logger.fine,
+		"call finished method="+method+" code="+grpcweb.Unavailable.String()+" elapsed=",
+		"retrying call method="+method+" attempt=2 delay=",
+		"call finished method="+method+" code="+grpcweb.NotFound.String()+" elapsed=")
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+const method = "/test.Service/Method"
+
+// message is a ProtoMessage holding its serialized form.
+// The clients return responses serialized, so
+// Deserialize is never called.
+type message []byte
+
+func (m message) Serialize() ([]byte, error) {
+	return m, nil
+}
+
+func (m message) Deserialize([]byte) error {
+	return nil
+}
+
+// newClients returns a client using transport for each encoding
+func newClients(transport grpcweb.Transport) map[string]*grpcweb.GRPCWebClientBase {
+	clients := map[string]*grpcweb.GRPCWebClientBase{}
+	for name, enc := range map[string]grpcweb.Encoding{
+		"binary": grpcweb.BinaryEncoding,
+		"text":   grpcweb.TextEncoding,
+	} {
+		clients[name] = grpcweb.NewGRPCWebClientBase(
+			grpcweb.WithTransport(transport),
+			grpcweb.WithEncoding(enc),
+		)
+	}
+
+	return clients
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package metadata defines the metadata sent with gRPC-Web calls.
+// It mirrors the API of the grpc-go metadata package.
+package metadata
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// MD is a mapping from metadata keys to values. Keys are lowercase,
+// as metadata keys are case insensitive. Values of binary keys, with
+// the "-bin" suffix, hold arbitrary bytes and are base64 encoded when
+// sent as headers.
+type MD map[string][]string
+
+// New creates an MD from a map of keys to values.
+// Uppercase letters in keys are converted to lowercase.
+func New(m map[string]string) MD {
+	md := make(MD, len(m))
+	for k, v := range m {
+		key := strings.ToLower(k)
+		md[key] = append(md[key], v)
+	}
+
+	return md
+}
+
+// Pairs returns an MD formed from the mapping of key, value pairs.
+// Pairs panics if kv has an odd length. Uppercase letters in keys
+// are converted to lowercase.
+func Pairs(kv ...string) MD {
+	if len(kv)%2 == 1 {
+		panic(fmt.Sprintf("metadata: Pairs got an odd number of input pairs for metadata: %d", len(kv)))
+	}
+
+	md := make(MD, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		key := strings.ToLower(kv[i])
+		md[key] = append(md[key], kv[i+1])
+	}
+
+	return md
+}
+
+// Join joins any number of MDs into a single MD. The values
+// of each key are in the order of the MDs they are joined from.
+func Join(mds ...MD) MD {
+	out := MD{}
+	for _, md := range mds {
+		for k, v := range md {
+			out[k] = append(out[k], v...)
+		}
+	}
+
+	return out
+}
+
+// Len returns the number of keys in md.
+func (md MD) Len() int {
+	return len(md)
+}
+
+// Copy returns a copy of md.
+func (md MD) Copy() MD {
+	out := make(MD, len(md))
+	for k, v := range md {
+		out[k] = append([]string(nil), v...)
+	}
+
+	return out
+}
+
+// Get returns the values of the key k. The key is case insensitive.
+func (md MD) Get(k string) []string {
+	return md[strings.ToLower(k)]
+}
+
+// Set sets the values of the key k, replacing any existing values.
+// The key is case insensitive. Setting no values deletes the key.
+func (md MD) Set(k string, vals ...string) {
+	if len(vals) == 0 {
+		md.Delete(k)
+		return
+	}
+
+	md[strings.ToLower(k)] = vals
+}
+
+// Append adds the values to the key k, keeping any existing values.
+// The key is case insensitive.
+func (md MD) Append(k string, vals ...string) {
+	if len(vals) == 0 {
+		return
+	}
+
+	k = strings.ToLower(k)
+	md[k] = append(md[k], vals...)
+}
+
+// Delete removes the values of the key k. The key is case insensitive.
+func (md MD) Delete(k string) {
+	delete(md, strings.ToLower(k))
+}
+
+// binHdrSuffix is the suffix of binary keys.
+const binHdrSuffix = "-bin"
+
+// IsBinaryKey reports whether the values of the key k are binary.
+func IsBinaryKey(k string) bool {
+	return strings.HasSuffix(strings.ToLower(k), binHdrSuffix)
+}
+
+// EncodeValue returns v, a value of the key k, as sent in a header.
+// Values of binary keys are base64 encoded, others are unchanged.
+func EncodeValue(k, v string) string {
+	if !IsBinaryKey(k) {
+		return v
+	}
+
+	return base64.RawStdEncoding.EncodeToString([]byte(v))
+}
+
+// DecodeValue returns the value of the key k from v, as received in a
+// header. Values of binary keys are base64 decoded, with or without
+// padding, and others are returned unchanged.
+func DecodeValue(k, v string) (string, error) {
+	if !IsBinaryKey(k) {
+		return v, nil
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(v, "="))
+	if err != nil {
+		return "", fmt.Errorf("metadata: malformed value of binary key %q: %v", k, err)
+	}
+
+	return string(b), nil
+}
+
+// AppendHeader adds the values of a header received with the key k to
+// md. Values of binary keys are decoded, and a header holding several
+// comma separated binary values is split into its values. Values that
+// fail to decode are dropped, and the first error is returned.
+func (md MD) AppendHeader(k string, vals ...string) error {
+	var firstErr error
+	k = strings.ToLower(k)
+	for _, v := range vals {
+		if !IsBinaryKey(k) {
+			md[k] = append(md[k], v)
+			continue
+		}
+
+		for _, part := range strings.Split(v, ",") {
+			decoded, err := DecodeValue(k, strings.TrimSpace(part))
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			md[k] = append(md[k], decoded)
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLowercaseKeys(t *testing.T) {
+	set := MD{}
+	set.Set("X-Key", "one")
+	set.Set("x-KEY", "two", "three")
+	appended := MD{}
+	appended.Append("X-Key", "one")
+	appended.Append("x-KEY", "two", "three")
+	appended.Append("X-Other")
+
+	for _, tt := range []struct {
+		name string
+		md   MD
+		want MD
+	}{
+		{"New", New(map[string]string{"X-Key": "one"}), MD{"x-key": {"one"}}},
+		{"Pairs", Pairs("X-Key", "one", "x-KEY", "two", "x-key", "three"), MD{"x-key": {"one", "two", "three"}}},
+		{"Set", set, MD{"x-key": {"two", "three"}}},
+		{"Append", appended, MD{"x-key": {"one", "two", "three"}}},
+	} {
+		if !reflect.DeepEqual(tt.md, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.md, tt.want)
+		}
+	}
+}
+
+func TestPairsOddLength(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Pairs did not panic with an odd number of arguments")
+		}
+	}()
+	Pairs("key")
+}
+
+func TestJoin(t *testing.T) {
+	a := Pairs("key", "a1", "only-a", "a")
+	b := Pairs("key", "b1", "key", "b2")
+	c := Pairs("key", "c1", "only-c", "c")
+
+	got := Join(a, b, c)
+	want := MD{
+		"key":    {"a1", "b1", "b2", "c1"},
+		"only-a": {"a"},
+		"only-c": {"c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The joined MDs are unchanged
+	got["key"][0] = "changed"
+	if a["key"][0] != "a1" {
+		t.Error("changing the joined MD changed an MD it was joined from")
+	}
+	if got := Join(); got == nil || got.Len() != 0 {
+		t.Errorf("Join() = %#v, want an empty MD", got)
+	}
+}
+
+func TestGetDelete(t *testing.T) {
+	for _, key := range []string{"x-key", "X-Key", "X-KEY"} {
+		md := Pairs("x-key", "value", "x-other", "other")
+		if got := md.Get(key); len(got) != 1 || got[0] != "value" {
+			t.Errorf("Get(%q) = %q, want %q", key, got, "value")
+		}
+
+		md.Delete(key)
+		if got := md.Get("x-key"); got != nil {
+			t.Errorf("got %q after Delete(%q), want no values", got, key)
+		}
+		if md.Len() != 1 {
+			t.Errorf("Delete(%q) removed other keys", key)
+		}
+	}
+
+	md := Pairs("x-key", "value")
+	md.Set("X-Key")
+	if md.Len() != 0 {
+		t.Errorf("Set without values kept %v", md)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	md := Pairs("key", "one")
+	cp := md.Copy()
+	cp.Append("key", "two")
+	cp["key"][0] = "changed"
+	if got := md.Get("key"); len(got) != 1 || got[0] != "one" {
+		t.Errorf("changing the copy changed the original to %q", got)
+	}
+}
+
+func TestBinaryValues(t *testing.T) {
+	for _, tt := range []struct {
+		key     string
+		value   string
+		encoded string
+	}{
+		{"x-key-bin", "\x00\x01\xfe\xff", "AAH+/w"},
+		{"X-Key-Bin", "a", "YQ"},
+		{"x-key-bin", "ab", "YWI"},
+		{"x-key-bin", "abc", "YWJj"},
+		{"x-key-bin", "", ""},
+		// Values of other keys are unchanged
+		{"x-key", "\x00value", "\x00value"},
+		{"x-bin-key", "value", "value"},
+	} {
+		encoded := EncodeValue(tt.key, tt.value)
+		if encoded != tt.encoded {
+			t.Errorf("EncodeValue(%q, %q) = %q, want %q", tt.key, tt.value, encoded, tt.encoded)
+		}
+
+		// Padding is optional
+		padded := encoded
+		if IsBinaryKey(tt.key) {
+			for len(padded)%4 != 0 {
+				padded += "="
+			}
+		}
+		for _, v := range []string{encoded, padded} {
+			got, err := DecodeValue(tt.key, v)
+			if err != nil || got != tt.value {
+				t.Errorf("DecodeValue(%q, %q) = %q, %v, want %q", tt.key, v, got, err, tt.value)
+			}
+		}
+	}
+
+	for _, v := range []string{"not base64!", "YQ=x", "Y"} {
+		if _, err := DecodeValue("x-key-bin", v); err == nil {
+			t.Errorf("DecodeValue(%q) succeeded, want an error", v)
+		}
+	}
+}
+
+func TestAppendHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		key     string
+		vals    []string
+		want    MD
+		wantErr bool
+	}{
+		{
+			name: "text",
+			key:  "X-Key",
+			vals: []string{"a, b", "c"},
+			want: MD{"x-key": {"a, b", "c"}},
+		},
+		{
+			name: "binary",
+			key:  "X-Key-Bin",
+			vals: []string{"YQ", "YWI="},
+			want: MD{"x-key-bin": {"a", "ab"}},
+		},
+		{
+			name: "comma separated binary",
+			key:  "x-key-bin",
+			vals: []string{"YQ==, YWI,YWJj", "AAH+/w"},
+			want: MD{"x-key-bin": {"a", "ab", "abc", "\x00\x01\xfe\xff"}},
+		},
+		{
+			name:    "malformed binary",
+			key:     "x-key-bin",
+			vals:    []string{"YQ,not base64!", "YWI"},
+			want:    MD{"x-key-bin": {"a", "ab"}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			md := MD{}
+			err := md.AppendHeader(tt.key, tt.vals...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want an error: %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(md, tt.want) {
+				t.Errorf("got %v, want %v", md, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build js && !wasm
+// +build js,!wasm
+
+package grpcweb
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// nodeSupported reports whether the program is running in
+// Node.js, where the http and https modules can be required.
+func nodeSupported() bool {
+	return js.Global.Get("process") != js.Undefined && js.Global.Get("require") != js.Undefined
+}
+
+// nodeTransport is a Transport using the Node.js
+// http and https modules, speaking the gRPC-Web protocol.
+type nodeTransport struct{}
+
+// NewNodeTransport returns a Transport that sends requests with
+// the Node.js http and https modules, using the gRPC-Web protocol.
+// It lets clients run under gopherjs test, where no XMLHttpRequest
+// is available.
+func NewNodeTransport() Transport {
+	return nodeTransport{}
+}
+
+// NewStream implements Transport.
+func (nodeTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	contentType, body, err := encodeBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	header := js.M{
+		"Content-Type": contentType,
+		"Accept":       contentType,
+		"X-Grpc-Web":   "1",
+	}
+	if req.Timeout > 0 {
+		header["grpc-timeout"] = encodeTimeout(req.Timeout)
+	}
+	compressionHeaders(req.Compressor, func(k, v string) {
+		header[k] = v
+	})
+
+	s := newNodeStream()
+	var text bool
+	var textDec base64Decoder
+	decoder := frameDecoder{maxMsgSize: req.MaxRecvMsgSize}
+	s.decode = func(chunk []byte) ([]*Frame, error) {
+		if !s.started {
+			s.started = true
+			text = isTextContentType(headerValue(s.header, "content-type"))
+		}
+		if text {
+			var err error
+			if chunk, err = textDec.decode(chunk); err != nil {
+				return nil, err
+			}
+		}
+		return decoder.decode(chunk)
+	}
+	s.end = func() {
+		// Trailers-only responses carry the status in the headers
+		if status, ok := statusFromMetadata(s.header); ok {
+			s.frames.put(&Frame{Status: status})
+			return
+		}
+		if s.httpStatus != 200 {
+			s.frames.fail(&Error{Code: FromHTTPStatus(s.httpStatus), Message: "unexpected HTTP status"})
+			return
+		}
+		s.frames.fail(&Error{Code: Internal, Message: "response ended without a status"})
+	}
+	s.send(req, header, body)
+
+	return s, nil
+}
+
+// nodeGatewayTransport is a Transport using the Node.js http and
+// https modules, speaking the gRPC-gateway streaming protocol.
+type nodeGatewayTransport struct{}
+
+// NewNodeGatewayTransport returns a Transport that sends requests
+// with the Node.js http and https modules, using the gRPC-gateway
+// streaming protocol. It lets clients run under gopherjs test,
+// where no XMLHttpRequest is available.
+func NewNodeGatewayTransport() Transport {
+	return nodeGatewayTransport{}
+}
+
+// NewStream implements Transport.
+func (nodeGatewayTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	header := js.M{
+		"Content-Type":                       "application/x-protobuf",
+		"X-Accept-Content-Transfer-Encoding": "base64",
+		"X-Accept-Response-Streaming":        "true",
+	}
+
+	s := newNodeStream()
+	var encoded bool
+	var textDec base64Decoder
+	decoder := gatewayDecoder{maxMsgSize: req.MaxRecvMsgSize}
+	s.decode = func(chunk []byte) ([]*Frame, error) {
+		if !s.started {
+			s.started = true
+			encoded = strings.ToLower(headerValue(s.header, "content-transfer-encoding")) == "base64"
+		}
+		if encoded {
+			var err error
+			if chunk, err = textDec.decode(chunk); err != nil {
+				return nil, err
+			}
+		}
+		return decoder.decode(chunk)
+	}
+	s.end = func() {
+		// Only has an effect if no status was received
+		if s.httpStatus != 200 {
+			s.frames.fail(&Error{Code: FromHTTPStatus(s.httpStatus), Message: "unexpected HTTP status"})
+			return
+		}
+		s.frames.fail(&Error{Code: Internal, Message: "stream ended without a status"})
+	}
+	s.send(req, header, req.Body)
+
+	return s, nil
+}
+
+// gatewayDecoder decodes the messages and status of a gRPC-gateway
+// streaming response, which is a stream of length delimited
+// protobuf fields, received in chunks with arbitrary boundaries.
+type gatewayDecoder struct {
+	buf []byte
+
+	// maxMsgSize is the largest message accepted,
+	// or 0 or less if there is no limit.
+	maxMsgSize int
+}
+
+// gRPC-gateway stream fields
+const (
+	gatewayMessageField = 1
+	gatewayStatusField  = 2
+)
+
+// decode adds the chunk to the data received so far and
+// returns all the frames that have been completely received.
+func (d *gatewayDecoder) decode(chunk []byte) ([]*Frame, error) {
+	d.buf = append(d.buf, chunk...)
+
+	var frames []*Frame
+	for len(d.buf) > 0 {
+		key, n := binary.Uvarint(d.buf)
+		if n == 0 {
+			break
+		}
+		if n < 0 || key&7 != 2 {
+			return frames, &Error{Code: Internal, Message: "malformed gateway stream"}
+		}
+		length, m := binary.Uvarint(d.buf[n:])
+		if m == 0 {
+			break
+		}
+		if m < 0 {
+			return frames, &Error{Code: Internal, Message: "malformed gateway stream"}
+		}
+		// Checked before the field is buffered or sliced
+		if err := checkFrameSize(length, n+m, key>>3 == gatewayMessageField, d.maxMsgSize); err != nil {
+			return frames, err
+		}
+		if uint64(len(d.buf)-n-m) < length {
+			break
+		}
+
+		payload := make([]byte, length)
+		copy(payload, d.buf[n+m:])
+		d.buf = d.buf[n+m+int(length):]
+
+		switch key >> 3 {
+		case gatewayMessageField:
+			frames = append(frames, &Frame{Message: payload})
+		case gatewayStatusField:
+			status, err := ParseRPCStatus(payload)
+			if err != nil {
+				return frames, &Error{Code: Internal, Message: err.Error(), Err: err}
+			}
+			frames = append(frames, &Frame{Status: status})
+		}
+	}
+
+	return frames, nil
+}
+
+// nodeStream is the TransportStream of the Node.js transports.
+// The protocol is implemented by the decode and end funcs.
+type nodeStream struct {
+	request    *js.Object
+	frames     *frameQueue
+	header     Metadata
+	httpStatus int
+	started    bool
+	timedOut   bool
+	timer      *js.Object
+
+	// decode decodes a chunk of the response body
+	decode func(chunk []byte) ([]*Frame, error)
+	// end is called once the whole response has been received
+	end func()
+}
+
+func newNodeStream() *nodeStream {
+	return &nodeStream{
+		frames: newFrameQueue(),
+		header: Metadata{},
+	}
+}
+
+// send sends the request with the http or https module,
+// depending on the scheme of the endpoint. The protocol headers
+// are set after the metadata of the request, replacing any
+// metadata with the same name, as the net/http transport does.
+func (s *nodeStream) send(req *TransportRequest, protocol js.M, body []byte) {
+	// Header names are case-insensitive, and the
+	// http module sends every key of the object.
+	header := js.M{}
+	headerValues(req.Header, func(k, v string) {
+		k = strings.ToLower(k)
+		vals, _ := header[k].([]string)
+		header[k] = append(vals, v)
+	})
+	for k, v := range protocol {
+		header[strings.ToLower(k)] = v
+	}
+
+	module := "http"
+	if strings.HasPrefix(req.Endpoint, "https:") {
+		module = "https"
+	}
+	s.request = js.Global.Call("require", module).Call("request", req.Endpoint, js.M{
+		"method":  POST.String(),
+		"headers": header,
+	})
+
+	s.request.Call("on", "response", func(resp *js.Object) {
+		// rawHeaders holds every header name and value in turn,
+		// keeping repeated headers as separate values.
+		raw := resp.Get("rawHeaders")
+		for i := 0; i+1 < raw.Length(); i += 2 {
+			// Malformed binary values are dropped
+			_ = s.header.AppendHeader(raw.Index(i).String(), raw.Index(i+1).String())
+		}
+		s.httpStatus = resp.Get("statusCode").Int()
+
+		resp.Call("on", "data", func(chunk *js.Object) {
+			frames, err := s.decode(js.Global.Get("Uint8Array").New(chunk).Interface().([]byte))
+			for _, f := range frames {
+				s.frames.put(f)
+			}
+			if err != nil {
+				s.frames.fail(err)
+				s.request.Call("destroy")
+			}
+		})
+		resp.Call("on", "end", func() {
+			s.end()
+		})
+	})
+	s.request.Call("on", "error", func(err *js.Object) {
+		if s.timedOut {
+			s.frames.fail(&Error{Code: DeadlineExceeded, Message: "request timed out"})
+			return
+		}
+		s.frames.fail(&Error{Code: Unavailable, Message: err.Get("message").String(), Err: &js.Error{Object: err}})
+	})
+	if req.Timeout > 0 {
+		s.timer = js.Global.Call("setTimeout", func() {
+			s.timedOut = true
+			s.request.Call("destroy")
+		}, timerMillis(req.Timeout))
+		// Pending timers keep Node.js from exiting
+		s.request.Call("on", "close", func() {
+			js.Global.Call("clearTimeout", s.timer)
+		})
+	}
+
+	s.request.Call("end", js.Global.Get("Buffer").Call("from", body))
+}
+
+func (s *nodeStream) Header() Metadata {
+	return s.header
+}
+
+func (s *nodeStream) Recv() (*Frame, error) {
+	return s.frames.get()
+}
+
+func (s *nodeStream) Close() {
+	s.frames.fail(&Error{Code: Cancelled, Message: "stream cancelled"})
+	s.request.Call("destroy")
+}
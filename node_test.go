@@ -0,0 +1,356 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build js && !wasm
+// +build js,!wasm
+
+package grpcweb_test
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+// nodeHandler serves a request to a Node.js http server.
+// msg is the request message, decoded from the body.
+type nodeHandler func(req *js.Object, msg []byte, res *nodeResponse)
+
+// newNodeServer starts a Node.js http server serving
+// gRPC-Web requests with h, and returns its URL.
+func newNodeServer(t *testing.T, h nodeHandler) string {
+	t.Helper()
+
+	server := js.Global.Call("require", "http").Call("createServer", func(req, res *js.Object) {
+		var body []byte
+		req.Call("on", "data", func(chunk *js.Object) {
+			body = append(body, js.Global.Get("Uint8Array").New(chunk).Interface().([]byte)...)
+		})
+		req.Call("on", "end", func() {
+			contentType := req.Get("headers").Get("content-type").String()
+			text := strings.HasPrefix(contentType, "application/grpc-web-text")
+			if text {
+				body, _ = base64.StdEncoding.DecodeString(string(body))
+			}
+			var msg []byte
+			if len(body) >= 5 {
+				msg = body[5:]
+			}
+			h(req, msg, &nodeResponse{res: res, contentType: contentType, text: text})
+		})
+	})
+
+	listening := make(chan struct{})
+	server.Call("listen", 0, "127.0.0.1", func() {
+		close(listening)
+	})
+	<-listening
+	t.Cleanup(func() {
+		server.Call("close")
+	})
+
+	return "http://127.0.0.1:" + strconv.Itoa(server.Call("address").Get("port").Int())
+}
+
+// nodeResponse writes a gRPC-Web response
+type nodeResponse struct {
+	res         *js.Object
+	contentType string
+	text        bool
+}
+
+// writeHeader writes the response headers
+func (r *nodeResponse) writeHeader(httpStatus int, header map[string]string) {
+	h := js.M{"content-type": r.contentType}
+	for k, v := range header {
+		h[k] = v
+	}
+	r.res.Call("writeHead", httpStatus, h)
+}
+
+// writeFrame writes a frame of the response body
+func (r *nodeResponse) writeFrame(flags byte, payload []byte) {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	if r.text {
+		r.res.Call("write", base64.StdEncoding.EncodeToString(frame))
+		return
+	}
+	r.res.Call("write", js.Global.Get("Buffer").Call("from", frame))
+}
+
+// writeStatus writes a trailer frame with the status
+// and trailers, and ends the response.
+func (r *nodeResponse) writeStatus(code grpcweb.StatusCode, message string, trailer map[string]string) {
+	block := "grpc-status: " + strconv.Itoa(int(code)) + "\r\n"
+	if message != "" {
+		block += "grpc-message: " + message + "\r\n"
+	}
+	for k, v := range trailer {
+		block += k + ": " + v + "\r\n"
+	}
+	r.writeFrame(0x80, []byte(block))
+	r.res.Call("end")
+}
+
+func TestNodeTransportRPCCall(t *testing.T) {
+	url := newNodeServer(t, func(req *js.Object, msg []byte, res *nodeResponse) {
+		res.writeHeader(200, map[string]string{"x-header": req.Get("headers").Get("x-metadata").String()})
+		res.writeFrame(0x00, append([]byte("echo "), msg...))
+		res.writeStatus(grpcweb.Ok, "", map[string]string{"x-trailer": "trailer value"})
+	})
+
+	for name, c := range newClients(grpcweb.NewNodeTransport()) {
+		t.Run(name, func(t *testing.T) {
+			var header, trailer grpcweb.Metadata
+			resp, err := c.RPCCall(url+method, message("hello"),
+				grpcweb.WithMetadata(grpcweb.Metadata{"x-metadata": {"metadata value"}}),
+				grpcweb.Header(&header),
+				grpcweb.Trailer(&trailer),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(resp) != "echo hello" {
+				t.Errorf("got response %q, want %q", resp, "echo hello")
+			}
+			if got := header.Get("x-header"); len(got) != 1 || got[0] != "metadata value" {
+				t.Errorf("got header %q, want the metadata sent with WithMetadata", got)
+			}
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+func TestNodeTransportMetadataProtocolHeaders(t *testing.T) {
+	url := newNodeServer(t, func(req *js.Object, msg []byte, res *nodeResponse) {
+		res.writeHeader(200, nil)
+		headers := req.Get("headers")
+		if ct := headers.Get("content-type").String(); ct != "application/grpc-web+proto" {
+			res.writeStatus(grpcweb.InvalidArgument, "content-type "+ct, nil)
+			return
+		}
+		if v := headers.Get("x-grpc-web").String(); v != "1" {
+			res.writeStatus(grpcweb.InvalidArgument, "x-grpc-web "+v, nil)
+			return
+		}
+		res.writeStatus(grpcweb.Ok, "", nil)
+	})
+
+	// Metadata must not replace the headers of the protocol
+	c := newClients(grpcweb.NewNodeTransport())["binary"]
+	_, err := c.RPCCall(url+method, message("hello"), grpcweb.WithMetadata(grpcweb.Metadata{
+		"content-type": {"text/plain"},
+		"x-grpc-web":   {"0"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNodeTransportServerStreaming(t *testing.T) {
+	want := []string{"one", "two", "three"}
+	url := newNodeServer(t, func(req *js.Object, msg []byte, res *nodeResponse) {
+		res.writeHeader(200, nil)
+		for _, m := range want {
+			res.writeFrame(0x00, []byte(m))
+		}
+		res.writeStatus(grpcweb.Ok, "", map[string]string{"x-trailer": "trailer value"})
+	})
+
+	for name, c := range newClients(grpcweb.NewNodeTransport()) {
+		t.Run(name, func(t *testing.T) {
+			var trailer grpcweb.Metadata
+			stream, err := c.ServerStreaming(url+method, message("hello"), grpcweb.Trailer(&trailer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			for _, w := range want {
+				msg, err := stream.Recv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(msg) != w {
+					t.Errorf("got message %q, want %q", msg, w)
+				}
+			}
+			if _, err := stream.Recv(); err != io.EOF {
+				t.Fatalf("got %v at the end of the stream, want io.EOF", err)
+			}
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+// encodeStatus encodes a google.rpc.Status
+func encodeStatus(t *testing.T, code grpcweb.StatusCode, message string, details ...grpcweb.Any) []byte {
+	t.Helper()
+
+	b := wire.NewBuffer(nil)
+	b.EncodeTag(1, wire.Varint)
+	b.EncodeVarint(uint64(code))
+	b.EncodeTag(2, wire.Bytes)
+	b.EncodeString(message)
+	for i := range details {
+		b.EncodeTag(3, wire.Bytes)
+		if err := b.EncodeMessage(&details[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func TestNodeTransportErrors(t *testing.T) {
+	detail := grpcweb.Any{TypeURL: "type.googleapis.com/test.Detail", Value: []byte{0x08, 0x01}}
+	status := encodeStatus(t, grpcweb.NotFound, "no such thing", detail)
+
+	st, err := grpcweb.ParseRPCStatus(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Code != grpcweb.NotFound || st.Message != "no such thing" || len(st.Details()) != 1 {
+		t.Fatalf("ParseRPCStatus returned %v %q %v, want NotFound %q with a detail", st.Code, st.Message, st.Details(), "no such thing")
+	}
+
+	url := newNodeServer(t, func(req *js.Object, msg []byte, res *nodeResponse) {
+		res.writeHeader(200, nil)
+		if string(msg) == "stream" {
+			res.writeFrame(0x00, []byte("first"))
+		}
+		res.writeStatus(grpcweb.NotFound, "no%20such%20thing", map[string]string{
+			"grpc-status-details-bin": metadata.EncodeValue("grpc-status-details-bin", string(status)),
+			"x-trailer":               "trailer value",
+		})
+	})
+
+	checkErr := func(t *testing.T, err error) {
+		t.Helper()
+		var e *grpcweb.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("got error %v, want a *grpcweb.Error", err)
+		}
+		if e.Code != grpcweb.NotFound || e.Message != "no such thing" {
+			t.Errorf("got error %v %q, want NotFound %q", e.Code, e.Message, "no such thing")
+		}
+		if len(e.Details) != 1 || e.Details[0].TypeURL != detail.TypeURL || string(e.Details[0].Value) != string(detail.Value) {
+			t.Errorf("got details %v, want %v", e.Details, detail)
+		}
+	}
+
+	for name, c := range newClients(grpcweb.NewNodeTransport()) {
+		t.Run(name, func(t *testing.T) {
+			var trailer grpcweb.Metadata
+			_, err := c.RPCCall(url+method, message("unary"), grpcweb.Trailer(&trailer))
+			checkErr(t, err)
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+
+			stream, err := c.ServerStreaming(url+method, message("stream"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			if msg, err := stream.Recv(); err != nil || string(msg) != "first" {
+				t.Fatalf("got %q, %v, want the message sent before the error", msg, err)
+			}
+			_, err = stream.Recv()
+			checkErr(t, err)
+		})
+	}
+}
+
+func TestNodeTransportTrailersOnly(t *testing.T) {
+	// A trailers-only response carries the status in the
+	// HTTP headers of a response without a body.
+	url := newNodeServer(t, func(req *js.Object, msg []byte, res *nodeResponse) {
+		res.writeHeader(200, map[string]string{
+			"grpc-status":  "7",
+			"grpc-message": "not%20allowed",
+			"x-trailer":    "trailer value",
+		})
+		res.res.Call("end")
+	})
+
+	for name, c := range newClients(grpcweb.NewNodeTransport()) {
+		t.Run(name, func(t *testing.T) {
+			var trailer grpcweb.Metadata
+			_, err := c.RPCCall(url+method, message("hello"), grpcweb.Trailer(&trailer))
+			var e *grpcweb.Error
+			if !errors.As(err, &e) || e.Code != grpcweb.PermissionDenied || e.Message != "not allowed" {
+				t.Fatalf("got error %v, want PermissionDenied %q", err, "not allowed")
+			}
+			if got := trailer.Get("x-trailer"); len(got) != 1 || got[0] != "trailer value" {
+				t.Errorf("got trailer %q, want %q", got, "trailer value")
+			}
+		})
+	}
+}
+
+func TestNodeTransportHTTPStatus(t *testing.T) {
+	for _, tt := range []struct {
+		httpStatus int
+		code       grpcweb.StatusCode
+	}{
+		{http.StatusBadRequest, grpcweb.Internal},
+		{http.StatusUnauthorized, grpcweb.Unauthenticated},
+		{http.StatusForbidden, grpcweb.PermissionDenied},
+		{http.StatusNotFound, grpcweb.Unimplemented},
+		{http.StatusServiceUnavailable, grpcweb.Unavailable},
+		{http.StatusInternalServerError, grpcweb.Unknown},
+	} {
+		t.Run(http.StatusText(tt.httpStatus), func(t *testing.T) {
+			if got := grpcweb.FromHTTPStatus(tt.httpStatus); got != tt.code {
+				t.Errorf("FromHTTPStatus(%d) = %v, want %v", tt.httpStatus, got, tt.code)
+			}
+
+			url := newNodeServer(t, func(req *js.Object, msg []byte, res *nodeResponse) {
+				res.writeHeader(tt.httpStatus, nil)
+				res.res.Call("end")
+			})
+
+			c := newClients(grpcweb.NewNodeTransport())["binary"]
+			_, err := c.RPCCall(url+method, message("hello"))
+			if !errors.Is(err, grpcweb.ErrCode(tt.code)) {
+				t.Errorf("got %v, want %v", err, tt.code)
+			}
+		})
+	}
+}
@@ -9,14 +9,31 @@ import (
 	"github.com/golang/protobuf/protoc-gen-go/generator"
 )
 
+// Options configure the generated code
+type Options struct {
+	// WebsocketTransport enables the generation of client streaming
+	// and bidirectional streaming methods, which require a client
+	// using a websocket transport.
+	WebsocketTransport bool
+	// PlainStructs generates plain Go structs for messages,
+	// without an embedded *js.Object or js struct tags. The
+	// generated code then builds for both GopherJS and
+	// WebAssembly (GOOS=js GOARCH=wasm).
+	PlainStructs bool
+}
+
 type FileGenerator struct {
 	w      io.Writer
 	indent uint
+	opts   Options
+	// pkg is the proto package of the file being generated
+	pkg string
 }
 
-func New(w io.Writer) *FileGenerator {
+func New(w io.Writer, opts Options) *FileGenerator {
 	return &FileGenerator{
-		w: w,
+		w:    w,
+		opts: opts,
 	}
 }
 
@@ -53,6 +70,8 @@ func (fg *FileGenerator) P(format string, a ...interface{}) error {
 }
 
 func (fg *FileGenerator) Generate(file *descriptor.FileDescriptorProto) {
+	fg.pkg = file.GetPackage()
+
 	fg.P(`package %s`, file.GetPackage())
 	fg.P("")
 
@@ -61,28 +80,65 @@ func (fg *FileGenerator) Generate(file *descriptor.FileDescriptorProto) {
 	fg.P("*/")
 	fg.P("")
 
-	fg.P(`import "github.com/gopherjs/gopherjs/js"`)
-	fg.P("")
+	fg.P(`import (`)
+	fg.In()
+	if !fg.opts.PlainStructs {
+		fg.P(`"github.com/gopherjs/gopherjs/js"`)
+	}
+	if len(file.GetService()) > 0 {
+		fg.P(`grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"`)
+	}
+	if len(file.GetMessageType()) > 0 {
+		fg.P(`"github.com/johanbrandhorst/gopherjs-grpc-web/wire"`)
+	}
+	fg.Out()
+	fg.P(`)`)
 
 	for _, msg := range file.GetMessageType() {
-		fg.generateProtoMessage(file, msg)
+		fg.P("")
+		fg.generateProtoMessage(generator.CamelCase(msg.GetName()), msg)
 	}
-}
 
-func (fg *FileGenerator) generateProtoMessage(file *descriptor.FileDescriptorProto, message *descriptor.DescriptorProto) {
-	ccTypeName := generator.CamelCase(message.GetName())
+	for _, service := range file.GetService() {
+		fg.P("")
+		fg.generateService(file, service)
+	}
+}
 
-	fg.P(`type %s struct {`, ccTypeName)
+// generateProtoMessage generates the message as the Go type
+// typeName, followed by its nested messages. Nested messages,
+// including the entries of map fields, are named after their
+// parent, separated by an underscore, as protoc-gen-go does.
+func (fg *FileGenerator) generateProtoMessage(typeName string, message *descriptor.DescriptorProto) {
+	fg.P(`type %s struct {`, typeName)
 	fg.In()
-	fg.P(`*js.Object`)
-	for _, field := range message.GetField() {
-		fg.P(`%s %s `+"`js:"+`"%s"`+"`", generator.CamelCase(field.GetName()), GoType(message, field), field.GetJsonName())
+	if fg.opts.PlainStructs {
+		for _, field := range message.GetField() {
+			fg.P(`%s %s`, generator.CamelCase(field.GetName()), fg.goType(message, field))
+		}
+	} else {
+		fg.P(`*js.Object`)
+		for _, field := range message.GetField() {
+			fg.P(`%s %s `+"`js:"+`"%s"`+"`", generator.CamelCase(field.GetName()), fg.goType(message, field), field.GetJsonName())
+		}
 	}
 	fg.Out()
 	fg.P(`}`)
+
+	fg.P("")
+	fg.generateSerialize(typeName, message)
+	fg.P("")
+	fg.generateDeserialize(typeName, message)
+
+	for _, nested := range message.GetNestedType() {
+		fg.P("")
+		fg.generateProtoMessage(typeName+"_"+generator.CamelCase(nested.GetName()), nested)
+	}
 }
 
-// GoType returns a string representing the type name
+// GoType returns a string representing the type name. Message and
+// group types are represented by their fully qualified proto type
+// name, and enums by int32, the type of their values.
 func GoType(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) (typ string) {
 	switch *field.Type {
 	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
@@ -103,7 +159,9 @@ func GoType(message *descriptor.DescriptorProto, field *descriptor.FieldDescript
 		typ = "string"
 	case descriptor.FieldDescriptorProto_TYPE_BYTES:
 		typ = "[]byte"
-	case descriptor.FieldDescriptorProto_TYPE_ENUM, descriptor.FieldDescriptorProto_TYPE_GROUP, descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		typ = "int32"
+	case descriptor.FieldDescriptorProto_TYPE_GROUP, descriptor.FieldDescriptorProto_TYPE_MESSAGE:
 		typ = field.GetTypeName()
 	default:
 		panic("unknown type for " + field.GetName())
@@ -117,6 +175,20 @@ func GoType(message *descriptor.DescriptorProto, field *descriptor.FieldDescript
 	return
 }
 
+// goType returns the type of the field in the generated code. It is
+// the type returned by GoType, with message types replaced by the
+// name of the Go type generated for them. Map fields are represented
+// as a slice of their entries.
+func (fg *FileGenerator) goType(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	typ := GoType(message, field)
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return typ
+	}
+
+	prefix := strings.TrimSuffix(typ, field.GetTypeName())
+	return prefix + fg.goTypeName(field.GetTypeName())
+}
+
 func needsStar(field *descriptor.FieldDescriptorProto, proto3 bool, allowOneOf bool) bool {
 	if isRepeated(field) &&
 		(*field.Type != descriptor.FieldDescriptorProto_TYPE_MESSAGE) &&
@@ -143,3 +215,20 @@ func needsStar(field *descriptor.FieldDescriptorProto, proto3 bool, allowOneOf b
 func isRepeated(field *descriptor.FieldDescriptorProto) bool {
 	return field.Label != nil && *field.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED
 }
+
+// goTypeName returns the name of the Go type generated for the
+// fully qualified proto type name. Only types in the package
+// of the generated file are supported.
+func (fg *FileGenerator) goTypeName(protoName string) string {
+	name := strings.TrimPrefix(protoName, ".")
+	if fg.pkg != "" {
+		name = strings.TrimPrefix(name, fg.pkg+".")
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = generator.CamelCase(part)
+	}
+
+	return strings.Join(parts, "_")
+}
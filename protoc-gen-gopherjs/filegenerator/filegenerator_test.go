@@ -0,0 +1,235 @@
+package filegenerator_test
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/protoc-gen-gopherjs/filegenerator"
+)
+
+var update = flag.Bool("update", false, "update the golden file")
+
+// goldenFiles are the golden files of the code generated
+// for ../test/test.proto with each of the options
+var goldenFiles = map[string]filegenerator.Options{
+	"../test/expected.go":               {},
+	"../test/plain/test.pb.gopherjs.go": {PlainStructs: true},
+}
+
+func field(name string, number int32, typ descriptor.FieldDescriptorProto_Type, label descriptor.FieldDescriptorProto_Label, typeName string) *descriptor.FieldDescriptorProto {
+	f := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+		JsonName: proto.String(jsonName(name)),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+
+	return f
+}
+
+// jsonName returns the JSON name protoc gives to the field name
+func jsonName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+
+	return strings.Join(parts, "")
+}
+
+func mapEntry(name string, key, value *descriptor.FieldDescriptorProto) *descriptor.DescriptorProto {
+	return &descriptor.DescriptorProto{
+		Name:    proto.String(name),
+		Field:   []*descriptor.FieldDescriptorProto{key, value},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+}
+
+// testFile returns the descriptor of ../test/test.proto
+func testFile() *descriptor.FileDescriptorProto {
+	const (
+		optional = descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptor.FieldDescriptorProto_LABEL_REPEATED
+		str      = descriptor.FieldDescriptorProto_TYPE_STRING
+		msg      = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	method := func(name string, clientStreaming, serverStreaming bool) *descriptor.MethodDescriptorProto {
+		return &descriptor.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".test.MyMessage"),
+			OutputType:      proto.String(".test.MyMessage"),
+			ClientStreaming: proto.Bool(clientStreaming),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+	}
+
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("MyMessage"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("msg", 1, str, optional, ""),
+					field("num", 2, descriptor.FieldDescriptorProto_TYPE_UINT32, optional, ""),
+				},
+			},
+			{
+				Name: proto.String("Outer"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("inner", 1, msg, optional, ".test.Outer.Inner"),
+					field("inners", 2, msg, repeated, ".test.Outer.Inner"),
+					field("counts", 3, msg, repeated, ".test.Outer.CountsEntry"),
+					field("named", 4, msg, repeated, ".test.Outer.NamedEntry"),
+				},
+				NestedType: []*descriptor.DescriptorProto{
+					{
+						Name:  proto.String("Inner"),
+						Field: []*descriptor.FieldDescriptorProto{field("value", 1, str, optional, "")},
+					},
+					mapEntry("CountsEntry",
+						field("key", 1, str, optional, ""),
+						field("value", 2, descriptor.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					),
+					mapEntry("NamedEntry",
+						field("key", 1, str, optional, ""),
+						field("value", 2, msg, optional, ".test.Outer.Inner"),
+					),
+				},
+			},
+			{
+				Name: proto.String("Scalars"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("double_value", 1, descriptor.FieldDescriptorProto_TYPE_DOUBLE, optional, ""),
+					field("float_value", 2, descriptor.FieldDescriptorProto_TYPE_FLOAT, optional, ""),
+					field("int64_value", 3, descriptor.FieldDescriptorProto_TYPE_INT64, optional, ""),
+					field("uint64_value", 4, descriptor.FieldDescriptorProto_TYPE_UINT64, optional, ""),
+					field("int32_value", 5, descriptor.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("uint32_value", 6, descriptor.FieldDescriptorProto_TYPE_UINT32, optional, ""),
+					field("sint32_value", 7, descriptor.FieldDescriptorProto_TYPE_SINT32, optional, ""),
+					field("sint64_value", 8, descriptor.FieldDescriptorProto_TYPE_SINT64, optional, ""),
+					field("fixed32_value", 9, descriptor.FieldDescriptorProto_TYPE_FIXED32, optional, ""),
+					field("sfixed32_value", 10, descriptor.FieldDescriptorProto_TYPE_SFIXED32, optional, ""),
+					field("fixed64_value", 11, descriptor.FieldDescriptorProto_TYPE_FIXED64, optional, ""),
+					field("sfixed64_value", 12, descriptor.FieldDescriptorProto_TYPE_SFIXED64, optional, ""),
+					field("bool_value", 13, descriptor.FieldDescriptorProto_TYPE_BOOL, optional, ""),
+					field("string_value", 14, str, optional, ""),
+					field("bytes_value", 15, descriptor.FieldDescriptorProto_TYPE_BYTES, optional, ""),
+					field("int32_values", 16, descriptor.FieldDescriptorProto_TYPE_INT32, repeated, ""),
+					field("sint64_values", 17, descriptor.FieldDescriptorProto_TYPE_SINT64, repeated, ""),
+					field("string_values", 18, str, repeated, ""),
+				},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("MyService"),
+				Method: []*descriptor.MethodDescriptorProto{
+					method("Unary", false, false),
+					method("ServerStream", false, true),
+					method("ClientStream", true, false),
+					method("BidiStream", true, true),
+				},
+			},
+		},
+	}
+}
+
+func generate(opts filegenerator.Options) []byte {
+	b := &bytes.Buffer{}
+	filegenerator.New(b, opts).Generate(testFile())
+	return b.Bytes()
+}
+
+func TestGenerateGolden(t *testing.T) {
+	for golden, opts := range goldenFiles {
+		// The golden files are formatted, like the output of
+		// protoc usually is before it is checked in.
+		got, err := format.Source(generate(opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err := os.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("generated code differs from %s, run go test -update to update it:\n%s", golden, got)
+		}
+	}
+}
+
+func TestGenerateTypeChecks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type checking imports from source")
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	for name, opts := range map[string]filegenerator.Options{
+		"default":   {},
+		"websocket": {WebsocketTransport: true},
+		"plain":     {PlainStructs: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, err := parser.ParseFile(fset, name+".go", generate(opts), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf := types.Config{Importer: imp}
+			pkg, err := conf.Check("test", fset, []*ast.File{f}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, typ := range []string{"Outer", "Outer_Inner", "Outer_CountsEntry", "Outer_NamedEntry"} {
+				if pkg.Scope().Lookup(typ) == nil {
+					t.Errorf("type %s was not generated", typ)
+				}
+			}
+		})
+	}
+}
+
+func TestGoType(t *testing.T) {
+	outer := testFile().GetMessageType()[1]
+	for _, tt := range []struct {
+		field *descriptor.FieldDescriptorProto
+		want  string
+	}{
+		{field("num", 1, descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_LABEL_OPTIONAL, ""), "uint32"},
+		{field("nums", 1, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_LABEL_REPEATED, ""), "[]int64"},
+		{field("kind", 1, descriptor.FieldDescriptorProto_TYPE_ENUM, descriptor.FieldDescriptorProto_LABEL_OPTIONAL, ".test.Kind"), "int32"},
+		// Message types are named by their proto type name
+		{outer.GetField()[0], "*.test.Outer.Inner"},
+		{outer.GetField()[1], "[]*.test.Outer.Inner"},
+	} {
+		if got := filegenerator.GoType(outer, tt.field); got != tt.want {
+			t.Errorf("GoType(%s) = %q, want %q", tt.field.GetName(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package filegenerator
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+)
+
+// wireCodec describes how a scalar field type is encoded
+type wireCodec struct {
+	// wireType is the name of the wire type in the wire package
+	wireType string
+	// encode is the name of the Buffer method used to encode values
+	encode string
+	// encodeType is the type the value is converted to before encoding
+	encodeType string
+	// decode is the name of the Buffer method used to decode values
+	decode string
+	// decodeType is the type returned by the decode method
+	decodeType string
+}
+
+var wireCodecs = map[descriptor.FieldDescriptorProto_Type]wireCodec{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   {"Fixed64", "EncodeDouble", "float64", "DecodeDouble", "float64"},
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    {"Fixed32", "EncodeFloat", "float32", "DecodeFloat", "float32"},
+	descriptor.FieldDescriptorProto_TYPE_INT64:    {"Varint", "EncodeVarint", "uint64", "DecodeVarint", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   {"Varint", "EncodeVarint", "uint64", "DecodeVarint", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_INT32:    {"Varint", "EncodeVarint", "uint64", "DecodeVarint", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   {"Varint", "EncodeVarint", "uint64", "DecodeVarint", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_ENUM:     {"Varint", "EncodeVarint", "uint64", "DecodeVarint", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   {"Varint", "EncodeZigzag", "int64", "DecodeZigzag", "int64"},
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   {"Varint", "EncodeZigzag", "int64", "DecodeZigzag", "int64"},
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  {"Fixed32", "EncodeFixed32", "uint32", "DecodeFixed32", "uint32"},
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: {"Fixed32", "EncodeFixed32", "uint32", "DecodeFixed32", "uint32"},
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  {"Fixed64", "EncodeFixed64", "uint64", "DecodeFixed64", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: {"Fixed64", "EncodeFixed64", "uint64", "DecodeFixed64", "uint64"},
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     {"Varint", "EncodeBool", "bool", "DecodeBool", "bool"},
+	descriptor.FieldDescriptorProto_TYPE_STRING:   {"Bytes", "EncodeString", "string", "DecodeString", "string"},
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    {"Bytes", "EncodeBytes", "[]byte", "DecodeBytes", "[]byte"},
+}
+
+// isPackable reports whether repeated fields of the
+// type are encoded as packed fields in proto3.
+func isPackable(field *descriptor.FieldDescriptorProto) bool {
+	c, ok := wireCodecs[field.GetType()]
+	return ok && c.wireType != "Bytes"
+}
+
+// elemType returns the Go type of a single value of the field
+func (fg *FileGenerator) elemType(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	typ := fg.goType(message, field)
+	if isRepeated(field) {
+		typ = typ[len("[]"):]
+	}
+
+	return typ
+}
+
+// newMessage returns an expression creating a new message of the Go type typ
+func (fg *FileGenerator) newMessage(typ string) string {
+	if fg.opts.PlainStructs {
+		return `&` + typ + `{}`
+	}
+
+	return `&` + typ + `{Object: js.Global.Get("Object").New()}`
+}
+
+func (fg *FileGenerator) generateSerialize(ccTypeName string, message *descriptor.DescriptorProto) {
+	fg.P(`// Serialize marshals %s to the protobuf wire format.`, ccTypeName)
+	fg.P(`func (m *%s) Serialize() ([]byte, error) {`, ccTypeName)
+	fg.In()
+	fg.P(`b := wire.NewBuffer(nil)`)
+	for _, field := range message.GetField() {
+		name := "m." + generator.CamelCase(field.GetName())
+		number := field.GetNumber()
+
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			if isRepeated(field) {
+				fg.P(`for _, v := range %s {`, name)
+			} else {
+				fg.P(`if v := %s; v != nil {`, name)
+			}
+			fg.In()
+			fg.P(`b.EncodeTag(%d, wire.Bytes)`, number)
+			fg.P(`if err := b.EncodeMessage(v); err != nil {`)
+			fg.In()
+			fg.P(`return nil, err`)
+			fg.Out()
+			fg.P(`}`)
+			fg.Out()
+			fg.P(`}`)
+			continue
+		}
+
+		c, ok := wireCodecs[field.GetType()]
+		if !ok {
+			// Groups are not supported
+			continue
+		}
+
+		switch {
+		case isRepeated(field) && isPackable(field):
+			fg.P(`if len(%s) > 0 {`, name)
+			fg.In()
+			fg.P(`p := wire.NewBuffer(nil)`)
+			fg.P(`for _, v := range %s {`, name)
+			fg.In()
+			if c.encodeType == fg.elemTypeName(field) {
+				fg.P(`p.%s(v)`, c.encode)
+			} else {
+				fg.P(`p.%s(%s(v))`, c.encode, c.encodeType)
+			}
+			fg.Out()
+			fg.P(`}`)
+			fg.P(`b.EncodeTag(%d, wire.Bytes)`, number)
+			fg.P(`b.EncodeBytes(p.Bytes())`)
+			fg.Out()
+			fg.P(`}`)
+		case isRepeated(field):
+			fg.P(`for _, v := range %s {`, name)
+			fg.In()
+			fg.P(`b.EncodeTag(%d, wire.%s)`, number, c.wireType)
+			fg.P(`b.%s(v)`, c.encode)
+			fg.Out()
+			fg.P(`}`)
+		default:
+			fg.P(`if v := %s; %s {`, name, nonZero(field))
+			fg.In()
+			fg.P(`b.EncodeTag(%d, wire.%s)`, number, c.wireType)
+			if c.encodeType == fg.elemTypeName(field) {
+				fg.P(`b.%s(v)`, c.encode)
+			} else {
+				fg.P(`b.%s(%s(v))`, c.encode, c.encodeType)
+			}
+			fg.Out()
+			fg.P(`}`)
+		}
+	}
+	fg.P("")
+	fg.P(`return b.Bytes(), nil`)
+	fg.Out()
+	fg.P(`}`)
+}
+
+func (fg *FileGenerator) generateDeserialize(ccTypeName string, message *descriptor.DescriptorProto) {
+	fg.P(`// Deserialize unmarshals %s from the protobuf wire format.`, ccTypeName)
+	fg.P(`func (m *%s) Deserialize(data []byte) error {`, ccTypeName)
+	fg.In()
+	fg.P(`b := wire.NewBuffer(data)`)
+	fg.P(`for !b.Done() {`)
+	fg.In()
+	fg.P(`field, typ, err := b.DecodeTag()`)
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`switch field {`)
+	for _, field := range message.GetField() {
+		name := "m." + generator.CamelCase(field.GetName())
+		typ := fg.elemType(message, field)
+
+		if field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			fg.P(`case %d:`, field.GetNumber())
+			fg.In()
+			fg.checkWireType("Bytes")
+			fg.P(`v := %s`, fg.newMessage(typ[len("*"):]))
+			fg.P(`err = b.DecodeMessage(v)`)
+			if isRepeated(field) {
+				fg.P(`%s = append(%s, v)`, name, name)
+			} else {
+				fg.P(`%s = v`, name)
+			}
+			fg.Out()
+			continue
+		}
+
+		c, ok := wireCodecs[field.GetType()]
+		if !ok {
+			// Groups are not supported, and skipped below
+			continue
+		}
+
+		assign := func(v string) string {
+			if c.decodeType != typ {
+				v = typ + "(" + v + ")"
+			}
+			if isRepeated(field) {
+				return name + " = append(" + name + ", " + v + ")"
+			}
+			return name + " = " + v
+		}
+
+		fg.P(`case %d:`, field.GetNumber())
+		fg.In()
+		if isRepeated(field) && isPackable(field) {
+			fg.P(`if typ == wire.Bytes {`)
+			fg.In()
+			fg.P(`err = b.DecodePacked(func(b *wire.Buffer) error {`)
+			fg.In()
+			fg.P(`v, err := b.%s()`, c.decode)
+			fg.P(`%s`, assign("v"))
+			fg.P(`return err`)
+			fg.Out()
+			fg.P(`})`)
+			fg.P(`break`)
+			fg.Out()
+			fg.P(`}`)
+		}
+		fg.checkWireType(c.wireType)
+		if c.decodeType == typ && !isRepeated(field) {
+			fg.P(`%s, err = b.%s()`, name, c.decode)
+		} else {
+			fg.P(`var v %s`, c.decodeType)
+			fg.P(`v, err = b.%s()`, c.decode)
+			fg.P(`%s`, assign("v"))
+		}
+		fg.Out()
+	}
+	fg.P(`default:`)
+	fg.In()
+	fg.P(`err = b.Skip(field, typ)`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`return nil`)
+	fg.Out()
+	fg.P(`}`)
+}
+
+// checkWireType generates a check that the wire type of the
+// field being decoded is the wire type named wireType.
+func (fg *FileGenerator) checkWireType(wireType string) {
+	fg.P(`if typ != wire.%s {`, wireType)
+	fg.In()
+	fg.P(`err = wire.ErrUnexpectedWireType`)
+	fg.P(`break`)
+	fg.Out()
+	fg.P(`}`)
+}
+
+// elemTypeName returns the Go type of a single scalar value of the field
+func (fg *FileGenerator) elemTypeName(field *descriptor.FieldDescriptorProto) string {
+	typ := fg.goType(nil, field)
+	if isRepeated(field) {
+		typ = typ[len("[]"):]
+	}
+
+	return typ
+}
+
+// nonZero returns an expression that is true if v
+// is not the zero value of the type of the field.
+func nonZero(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "v"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return `v != ""`
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "len(v) > 0"
+	default:
+		return "v != 0"
+	}
+}
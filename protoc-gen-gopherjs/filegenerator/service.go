@@ -0,0 +1,312 @@
+package filegenerator
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+)
+
+func (fg *FileGenerator) generateService(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	serviceName := service.GetName()
+	if file.GetPackage() != "" {
+		serviceName = file.GetPackage() + "." + serviceName
+	}
+	clientName := generator.CamelCase(service.GetName()) + "Client"
+	implName := unexport(clientName)
+
+	var methods []*descriptor.MethodDescriptorProto
+	for _, method := range service.GetMethod() {
+		if method.GetClientStreaming() && !fg.opts.WebsocketTransport {
+			// Client streaming requires a websocket transport
+			continue
+		}
+		methods = append(methods, method)
+	}
+
+	fg.P(`// %s is a client for the %s service.`, clientName, serviceName)
+	fg.P(`type %s interface {`, clientName)
+	fg.In()
+	for _, method := range methods {
+		fg.P(`%s(%s) %s`, generator.CamelCase(method.GetName()), fg.methodParams(method), fg.methodResults(service, method))
+	}
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`type %s struct {`, implName)
+	fg.In()
+	fg.P(`client grpcweb.Client`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`// New%s creates a new %s making calls`, clientName, clientName)
+	fg.P(`// with the provided client, usually a *grpcweb.ClientConn.`)
+	fg.P(`func New%s(client grpcweb.Client) %s {`, clientName, clientName)
+	fg.In()
+	fg.P(`return &%s{client: client}`, implName)
+	fg.Out()
+	fg.P(`}`)
+
+	for _, method := range methods {
+		fg.P("")
+		switch {
+		case method.GetClientStreaming() && method.GetServerStreaming():
+			fg.generateBidiStreamingMethod(service, serviceName, implName, method)
+		case method.GetClientStreaming():
+			fg.generateClientStreamingMethod(service, serviceName, implName, method)
+		case method.GetServerStreaming():
+			fg.generateServerStreamingMethod(service, serviceName, implName, method)
+		default:
+			fg.generateUnaryMethod(serviceName, implName, method)
+		}
+	}
+
+	fg.P("")
+	fg.generateMock(service, clientName, methods)
+}
+
+// generateMock generates a mock implementation of the client
+// interface, with a settable func for each method.
+func (fg *FileGenerator) generateMock(service *descriptor.ServiceDescriptorProto, clientName string, methods []*descriptor.MethodDescriptorProto) {
+	mockName := "Mock" + clientName
+
+	fg.P(`// %s is a %s for use in tests. Each method calls`, mockName, clientName)
+	fg.P(`// the func field of the same name with a Func suffix, or`)
+	fg.P(`// returns an Unimplemented error if the field is nil.`)
+	fg.P(`type %s struct {`, mockName)
+	fg.In()
+	for _, method := range methods {
+		fg.P(`%sFunc func(%s) %s`, generator.CamelCase(method.GetName()), fg.methodParams(method), fg.methodResults(service, method))
+	}
+	fg.Out()
+	fg.P(`}`)
+
+	for _, method := range methods {
+		methodName := generator.CamelCase(method.GetName())
+		args := "opts..."
+		if !method.GetClientStreaming() {
+			args = "req, opts..."
+		}
+
+		fg.P("")
+		fg.P(`// %s calls %sFunc.`, methodName, methodName)
+		fg.P(`func (m *%s) %s(%s) %s {`, mockName, methodName, fg.methodParams(method), fg.methodResults(service, method))
+		fg.In()
+		fg.P(`if m.%sFunc == nil {`, methodName)
+		fg.In()
+		fg.P(`return nil, &grpcweb.Error{Code: grpcweb.Unimplemented, Message: "%s.%sFunc is not set"}`, mockName, methodName)
+		fg.Out()
+		fg.P(`}`)
+		fg.P("")
+		fg.P(`return m.%sFunc(%s)`, methodName, args)
+		fg.Out()
+		fg.P(`}`)
+	}
+}
+
+// methodParams returns the parameters of the client method for the method
+func (fg *FileGenerator) methodParams(method *descriptor.MethodDescriptorProto) string {
+	if method.GetClientStreaming() {
+		return `opts ...grpcweb.CallOption`
+	}
+
+	return `req *` + fg.goTypeName(method.GetInputType()) + `, opts ...grpcweb.CallOption`
+}
+
+// methodResults returns the results of the client method for the method
+func (fg *FileGenerator) methodResults(service *descriptor.ServiceDescriptorProto, method *descriptor.MethodDescriptorProto) string {
+	if method.GetClientStreaming() || method.GetServerStreaming() {
+		return `(` + generator.CamelCase(service.GetName()) + "_" + generator.CamelCase(method.GetName()) + `Client, error)`
+	}
+
+	return `(*` + fg.goTypeName(method.GetOutputType()) + `, error)`
+}
+
+// generateDeserializeResponse generates code deserializing
+// the bytes in the variable resp to a message of type typ
+// in the variable out, returning any error.
+func (fg *FileGenerator) generateDeserializeResponse(typ string) {
+	fg.P(`out := %s`, fg.newMessage(typ))
+	fg.P(`if err = out.Deserialize(resp); err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`return out, nil`)
+}
+
+func (fg *FileGenerator) generateUnaryMethod(serviceName, clientName string, method *descriptor.MethodDescriptorProto) {
+	methodName := generator.CamelCase(method.GetName())
+	inType, outType := fg.goTypeName(method.GetInputType()), fg.goTypeName(method.GetOutputType())
+
+	fg.P(`// %s calls the %s method of the %s service.`, methodName, method.GetName(), serviceName)
+	fg.P(`func (c *%s) %s(req *%s, opts ...grpcweb.CallOption) (*%s, error) {`, clientName, methodName, inType, outType)
+	fg.In()
+	fg.P(`resp, err := c.client.RPCCall("/%s/%s", req, opts...)`, serviceName, method.GetName())
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.generateDeserializeResponse(outType)
+	fg.Out()
+	fg.P(`}`)
+}
+
+func (fg *FileGenerator) generateServerStreamingMethod(service *descriptor.ServiceDescriptorProto, serviceName, clientName string, method *descriptor.MethodDescriptorProto) {
+	methodName := generator.CamelCase(method.GetName())
+	inType, outType := fg.goTypeName(method.GetInputType()), fg.goTypeName(method.GetOutputType())
+	streamName := generator.CamelCase(service.GetName()) + "_" + methodName + "Client"
+	implName := unexport(generator.CamelCase(service.GetName())) + methodName + "Client"
+
+	fg.P(`// %s calls the %s method of the %s service.`, methodName, method.GetName(), serviceName)
+	fg.P(`func (c *%s) %s(req *%s, opts ...grpcweb.CallOption) (%s, error) {`, clientName, methodName, inType, streamName)
+	fg.In()
+	fg.P(`stream, err := c.client.ServerStreaming("/%s/%s", req, opts...)`, serviceName, method.GetName())
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`return &%s{ClientStream: stream}, nil`, implName)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`// %s receives the responses of the %s method.`, streamName, method.GetName())
+	fg.P(`type %s interface {`, streamName)
+	fg.In()
+	fg.P(`Recv() (*%s, error)`, outType)
+	fg.P(`Close()`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`type %s struct {`, implName)
+	fg.In()
+	fg.P(`grpcweb.ClientStream`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.generateRecv(implName, "ClientStream", outType)
+}
+
+func (fg *FileGenerator) generateClientStreamingMethod(service *descriptor.ServiceDescriptorProto, serviceName, clientName string, method *descriptor.MethodDescriptorProto) {
+	methodName := generator.CamelCase(method.GetName())
+	inType, outType := fg.goTypeName(method.GetInputType()), fg.goTypeName(method.GetOutputType())
+	streamName := generator.CamelCase(service.GetName()) + "_" + methodName + "Client"
+	implName := unexport(generator.CamelCase(service.GetName())) + methodName + "Client"
+
+	fg.P(`// %s calls the %s method of the %s service.`, methodName, method.GetName(), serviceName)
+	fg.P(`func (c *%s) %s(opts ...grpcweb.CallOption) (%s, error) {`, clientName, methodName, streamName)
+	fg.In()
+	fg.P(`stream, err := c.client.ClientStreaming("/%s/%s", opts...)`, serviceName, method.GetName())
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`return &%s{StreamWriter: stream}, nil`, implName)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`// %s sends the requests of the %s method.`, streamName, method.GetName())
+	fg.P(`type %s interface {`, streamName)
+	fg.In()
+	fg.P(`Send(*%s) error`, inType)
+	fg.P(`CloseAndRecv() (*%s, error)`, outType)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`type %s struct {`, implName)
+	fg.In()
+	fg.P(`grpcweb.StreamWriter`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.generateSend(implName, "StreamWriter", inType)
+	fg.P("")
+	fg.P(`func (x *%s) CloseAndRecv() (*%s, error) {`, implName, outType)
+	fg.In()
+	fg.P(`resp, err := x.StreamWriter.CloseAndRecv()`)
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.generateDeserializeResponse(outType)
+	fg.Out()
+	fg.P(`}`)
+}
+
+func (fg *FileGenerator) generateBidiStreamingMethod(service *descriptor.ServiceDescriptorProto, serviceName, clientName string, method *descriptor.MethodDescriptorProto) {
+	methodName := generator.CamelCase(method.GetName())
+	inType, outType := fg.goTypeName(method.GetInputType()), fg.goTypeName(method.GetOutputType())
+	streamName := generator.CamelCase(service.GetName()) + "_" + methodName + "Client"
+	implName := unexport(generator.CamelCase(service.GetName())) + methodName + "Client"
+
+	fg.P(`// %s calls the %s method of the %s service.`, methodName, method.GetName(), serviceName)
+	fg.P(`func (c *%s) %s(opts ...grpcweb.CallOption) (%s, error) {`, clientName, methodName, streamName)
+	fg.In()
+	fg.P(`stream, err := c.client.BidiStreaming("/%s/%s", opts...)`, serviceName, method.GetName())
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`return &%s{BidiStream: stream}, nil`, implName)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`// %s sends the requests and receives the responses of the %s method.`, streamName, method.GetName())
+	fg.P(`type %s interface {`, streamName)
+	fg.In()
+	fg.P(`Send(*%s) error`, inType)
+	fg.P(`CloseSend() error`)
+	fg.P(`Recv() (*%s, error)`, outType)
+	fg.P(`Close()`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.P(`type %s struct {`, implName)
+	fg.In()
+	fg.P(`grpcweb.BidiStream`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.generateSend(implName, "BidiStream", inType)
+	fg.P("")
+	fg.generateRecv(implName, "BidiStream", outType)
+}
+
+func (fg *FileGenerator) generateSend(implName, embedded, inType string) {
+	fg.P(`func (x *%s) Send(req *%s) error {`, implName, inType)
+	fg.In()
+	fg.P(`return x.%s.Send(req)`, embedded)
+	fg.Out()
+	fg.P(`}`)
+}
+
+func (fg *FileGenerator) generateRecv(implName, embedded, outType string) {
+	fg.P(`func (x *%s) Recv() (*%s, error) {`, implName, outType)
+	fg.In()
+	fg.P(`resp, err := x.%s.Recv()`, embedded)
+	fg.P(`if err != nil {`)
+	fg.In()
+	fg.P(`return nil, err`)
+	fg.Out()
+	fg.P(`}`)
+	fg.P("")
+	fg.generateDeserializeResponse(outType)
+	fg.Out()
+	fg.P(`}`)
+}
+
+// unexport lowercases the first letter of name
+func unexport(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -5,6 +5,7 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -30,12 +31,17 @@ func main() {
 		log.Fatalln("Could not unmarshal request: ", err)
 	}
 
+	opts, err := parseParameter(req.GetParameter())
+	if err != nil {
+		log.Fatalln("Could not parse parameter: ", err)
+	}
+
 	resp := &plugin.CodeGeneratorResponse{}
 
 	for _, inFile := range req.GetProtoFile() {
 		for _, reqFile := range req.GetFileToGenerate() {
 			if inFile.GetName() == reqFile {
-				outFile, err := processFile(inFile)
+				outFile, err := processFile(inFile, opts)
 				if err != nil {
 					log.Fatalln("Could not process file: ", err)
 				}
@@ -55,12 +61,31 @@ func main() {
 	}
 }
 
-func processFile(inFile *descriptor.FileDescriptorProto) (*plugin.CodeGeneratorResponse_File, error) {
+// parseParameter parses the comma separated
+// parameter passed to the plugin by protoc.
+func parseParameter(parameter string) (filegenerator.Options, error) {
+	var opts filegenerator.Options
+	for _, p := range strings.Split(parameter, ",") {
+		switch p {
+		case "":
+		case "transport=websocket":
+			opts.WebsocketTransport = true
+		case "structs=plain":
+			opts.PlainStructs = true
+		default:
+			return opts, fmt.Errorf("unknown parameter %q", p)
+		}
+	}
+
+	return opts, nil
+}
+
+func processFile(inFile *descriptor.FileDescriptorProto, opts filegenerator.Options) (*plugin.CodeGeneratorResponse_File, error) {
 	outFile := &plugin.CodeGeneratorResponse_File{}
 	outFile.Name = proto.String(strings.TrimSuffix(inFile.GetName(), ".proto") + ".pb.gopherjs.go")
 
 	b := &bytes.Buffer{}
-	fg := filegenerator.New(b)
+	fg := filegenerator.New(b, opts)
 
 	fg.Generate(inFile)
 
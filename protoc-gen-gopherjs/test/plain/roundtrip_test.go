@@ -0,0 +1,236 @@
+package test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+func scalars() *Scalars {
+	return &Scalars{
+		DoubleValue:   -1.5,
+		FloatValue:    3.25,
+		Int64Value:    math.MinInt64,
+		Uint64Value:   math.MaxUint64,
+		Int32Value:    -1,
+		Uint32Value:   math.MaxUint32,
+		Sint32Value:   math.MinInt32,
+		Sint64Value:   math.MinInt64,
+		Fixed32Value:  math.MaxUint32,
+		Sfixed32Value: -2,
+		Fixed64Value:  math.MaxUint64,
+		Sfixed64Value: -3,
+		BoolValue:     true,
+		StringValue:   "héllo",
+		BytesValue:    []byte{0, 0xff},
+		Int32Values:   []int32{1, -1, 300},
+		Sint64Values:  []int64{-1, 1, math.MinInt64},
+		StringValues:  []string{"a", "", "b"},
+	}
+}
+
+// encodeScalars encodes m with the protobuf runtime's wire package
+func encodeScalars(m *Scalars) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(m.DoubleValue))
+	b = protowire.AppendTag(b, 2, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, math.Float32bits(m.FloatValue))
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Int64Value))
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, m.Uint64Value)
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Int32Value))
+	b = protowire.AppendTag(b, 6, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Uint32Value))
+	b = protowire.AppendTag(b, 7, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(m.Sint32Value)))
+	b = protowire.AppendTag(b, 8, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(m.Sint64Value))
+	b = protowire.AppendTag(b, 9, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, m.Fixed32Value)
+	b = protowire.AppendTag(b, 10, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, uint32(m.Sfixed32Value))
+	b = protowire.AppendTag(b, 11, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, m.Fixed64Value)
+	b = protowire.AppendTag(b, 12, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(m.Sfixed64Value))
+	b = protowire.AppendTag(b, 13, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeBool(m.BoolValue))
+	b = protowire.AppendTag(b, 14, protowire.BytesType)
+	b = protowire.AppendString(b, m.StringValue)
+	b = protowire.AppendTag(b, 15, protowire.BytesType)
+	b = protowire.AppendBytes(b, m.BytesValue)
+
+	// Repeated scalars are packed
+	var packed []byte
+	for _, v := range m.Int32Values {
+		packed = protowire.AppendVarint(packed, uint64(v))
+	}
+	b = protowire.AppendTag(b, 16, protowire.BytesType)
+	b = protowire.AppendBytes(b, packed)
+	packed = nil
+	for _, v := range m.Sint64Values {
+		packed = protowire.AppendVarint(packed, protowire.EncodeZigZag(v))
+	}
+	b = protowire.AppendTag(b, 17, protowire.BytesType)
+	b = protowire.AppendBytes(b, packed)
+	for _, v := range m.StringValues {
+		b = protowire.AppendTag(b, 18, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	}
+
+	return b
+}
+
+func TestScalarsRoundTrip(t *testing.T) {
+	m := scalars()
+	want := encodeScalars(m)
+
+	got, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Serialize returned\n%x\nwant\n%x", got, want)
+	}
+
+	decoded := &Scalars{}
+	if err := decoded.Deserialize(want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("Deserialize returned %+v, want %+v", decoded, m)
+	}
+}
+
+func TestScalarsZeroValues(t *testing.T) {
+	// Fields with zero values are not encoded
+	b, err := (&Scalars{}).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("got %x for zero values, want no bytes", b)
+	}
+}
+
+func TestScalarsUnpacked(t *testing.T) {
+	// Parsers must accept unpacked repeated scalars
+	var b []byte
+	for _, v := range []int32{1, -1, 300} {
+		b = protowire.AppendTag(b, 16, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v))
+	}
+	b = protowire.AppendTag(b, 17, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(-5))
+
+	m := &Scalars{}
+	if err := m.Deserialize(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Int32Values, []int32{1, -1, 300}) || !reflect.DeepEqual(m.Sint64Values, []int64{-5}) {
+		t.Errorf("got %v and %v, want the unpacked values", m.Int32Values, m.Sint64Values)
+	}
+}
+
+func TestScalarsUnknownFields(t *testing.T) {
+	var b []byte
+	b = protowire.AppendTag(b, 100, protowire.BytesType)
+	b = protowire.AppendString(b, "unknown")
+	b = protowire.AppendTag(b, 14, protowire.BytesType)
+	b = protowire.AppendString(b, "known")
+	b = protowire.AppendTag(b, 101, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, 1)
+
+	m := &Scalars{}
+	if err := m.Deserialize(b); err != nil {
+		t.Fatal(err)
+	}
+	if m.StringValue != "known" {
+		t.Errorf("got %q, want %q", m.StringValue, "known")
+	}
+}
+
+func TestOuterRoundTrip(t *testing.T) {
+	m := &Outer{
+		Inner:  &Outer_Inner{Value: "inner"},
+		Inners: []*Outer_Inner{{Value: "one"}, {}, {Value: "three"}},
+		Counts: []*Outer_CountsEntry{{Key: "a", Value: 1}, {Key: "b", Value: -2}},
+		Named:  []*Outer_NamedEntry{{Key: "x", Value: &Outer_Inner{Value: "named"}}},
+	}
+
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Outer{}
+	if err := decoded.Deserialize(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("Deserialize returned %+v, want %+v", decoded, m)
+	}
+}
+
+func TestDeserializeTruncated(t *testing.T) {
+	b := encodeScalars(scalars())
+	for i := 1; i < len(b); i++ {
+		m := &Scalars{}
+		// Cutting between fields leaves a valid message,
+		// any other cut is an error
+		if err := m.Deserialize(b[:i]); err != nil {
+			continue
+		}
+		if got, _ := m.Serialize(); !bytes.Equal(got, b[:i]) {
+			t.Fatalf("truncated at %d: got a message encoding as %x", i, got)
+		}
+	}
+}
+
+func TestDeserializeUnexpectedWireType(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		field protowire.Number
+		typ   protowire.Type
+	}{
+		{"varint as fixed64", 3, protowire.Fixed64Type},
+		{"double as varint", 1, protowire.VarintType},
+		{"float as fixed64", 2, protowire.Fixed64Type},
+		{"fixed32 as bytes", 9, protowire.BytesType},
+		{"string as varint", 14, protowire.VarintType},
+		{"repeated string as varint", 18, protowire.VarintType},
+		{"packable as fixed32", 16, protowire.Fixed32Type},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := protowire.AppendTag(nil, tt.field, tt.typ)
+			switch tt.typ {
+			case protowire.VarintType:
+				b = protowire.AppendVarint(b, 1)
+			case protowire.Fixed32Type:
+				b = protowire.AppendFixed32(b, 1)
+			case protowire.Fixed64Type:
+				b = protowire.AppendFixed64(b, 1)
+			case protowire.BytesType:
+				b = protowire.AppendString(b, "1")
+			}
+
+			if err := (&Scalars{}).Deserialize(b); err != wire.ErrUnexpectedWireType {
+				t.Errorf("got %v, want ErrUnexpectedWireType", err)
+			}
+		})
+	}
+
+	// Message fields are length delimited
+	b := protowire.AppendTag(nil, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	if err := (&Outer{}).Deserialize(b); err != wire.ErrUnexpectedWireType {
+		t.Errorf("got %v for a message field, want ErrUnexpectedWireType", err)
+	}
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+)
+
+func TestMyServiceClientUnary(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script("/test.MyService/Unary", &grpcweb.FakeResponse{
+		Messages: []grpcweb.ProtoMessage{&MyMessage{Msg: "pong", Num: 2}},
+	})
+	c := NewMyServiceClient(grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport)))
+
+	resp, err := c.Unary(&MyMessage{Msg: "ping", Num: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Msg != "pong" || resp.Num != 2 {
+		t.Errorf("got %+v, want the scripted response", resp)
+	}
+
+	requests := transport.Requests()
+	if len(requests) != 1 || requests[0].Endpoint != "/test.MyService/Unary" {
+		t.Fatalf("got requests %+v, want one to /test.MyService/Unary", requests)
+	}
+}
+
+func TestMyServiceClientServerStream(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script("/test.MyService/ServerStream", &grpcweb.FakeResponse{
+		Messages: []grpcweb.ProtoMessage{&MyMessage{Num: 1}, &MyMessage{Num: 2}},
+		Status:   &grpcweb.Status{Code: grpcweb.Aborted},
+	})
+	c := NewMyServiceClient(grpcweb.NewGRPCWebClientBase(grpcweb.WithTransport(transport)))
+
+	stream, err := c.ServerStream(&MyMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	for _, want := range []uint32{1, 2} {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Num != want {
+			t.Errorf("got %d, want %d", resp.Num, want)
+		}
+	}
+	if _, err := stream.Recv(); !errors.Is(err, grpcweb.ErrCode(grpcweb.Aborted)) {
+		t.Errorf("got %v, want the scripted Aborted status", err)
+	}
+}
+
+func TestMockMyServiceClient(t *testing.T) {
+	var c MyServiceClient = &MockMyServiceClient{}
+	if _, err := c.Unary(&MyMessage{}); !errors.Is(err, grpcweb.ErrCode(grpcweb.Unimplemented)) {
+		t.Errorf("got %v from an unset UnaryFunc, want an Unimplemented error", err)
+	}
+	if _, err := c.ServerStream(&MyMessage{}); !errors.Is(err, grpcweb.ErrCode(grpcweb.Unimplemented)) {
+		t.Errorf("got %v from an unset ServerStreamFunc, want an Unimplemented error", err)
+	}
+
+	var got *MyMessage
+	c = &MockMyServiceClient{
+		UnaryFunc: func(req *MyMessage, opts ...grpcweb.CallOption) (*MyMessage, error) {
+			got = req
+			return &MyMessage{Msg: "mocked"}, nil
+		},
+	}
+	req := &MyMessage{Msg: "req"}
+	resp, err := c.Unary(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != req || resp.Msg != "mocked" {
+		t.Errorf("got request %+v and response %+v, want the request passed and the mocked response", got, resp)
+	}
+}
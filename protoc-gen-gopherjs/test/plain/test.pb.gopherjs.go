@@ -0,0 +1,672 @@
+package test
+
+/*
+This file is generated by protoc-gen-gopherjs, DO NOT EDIT.
+*/
+
+import (
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+type MyMessage struct {
+	Msg string
+	Num uint32
+}
+
+// Serialize marshals MyMessage to the protobuf wire format.
+func (m *MyMessage) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.Msg; v != "" {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := m.Num; v != 0 {
+		b.EncodeTag(2, wire.Varint)
+		b.EncodeVarint(uint64(v))
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals MyMessage from the protobuf wire format.
+func (m *MyMessage) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Msg, err = b.DecodeString()
+		case 2:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeVarint()
+			m.Num = uint32(v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Outer struct {
+	Inner  *Outer_Inner
+	Inners []*Outer_Inner
+	Counts []*Outer_CountsEntry
+	Named  []*Outer_NamedEntry
+}
+
+// Serialize marshals Outer to the protobuf wire format.
+func (m *Outer) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.Inner; v != nil {
+		b.EncodeTag(1, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range m.Inners {
+		b.EncodeTag(2, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range m.Counts {
+		b.EncodeTag(3, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range m.Named {
+		b.EncodeTag(4, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals Outer from the protobuf wire format.
+func (m *Outer) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			v := &Outer_Inner{}
+			err = b.DecodeMessage(v)
+			m.Inner = v
+		case 2:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			v := &Outer_Inner{}
+			err = b.DecodeMessage(v)
+			m.Inners = append(m.Inners, v)
+		case 3:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			v := &Outer_CountsEntry{}
+			err = b.DecodeMessage(v)
+			m.Counts = append(m.Counts, v)
+		case 4:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			v := &Outer_NamedEntry{}
+			err = b.DecodeMessage(v)
+			m.Named = append(m.Named, v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Outer_Inner struct {
+	Value string
+}
+
+// Serialize marshals Outer_Inner to the protobuf wire format.
+func (m *Outer_Inner) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.Value; v != "" {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals Outer_Inner from the protobuf wire format.
+func (m *Outer_Inner) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Value, err = b.DecodeString()
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Outer_CountsEntry struct {
+	Key   string
+	Value int32
+}
+
+// Serialize marshals Outer_CountsEntry to the protobuf wire format.
+func (m *Outer_CountsEntry) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.Key; v != "" {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := m.Value; v != 0 {
+		b.EncodeTag(2, wire.Varint)
+		b.EncodeVarint(uint64(v))
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals Outer_CountsEntry from the protobuf wire format.
+func (m *Outer_CountsEntry) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Key, err = b.DecodeString()
+		case 2:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeVarint()
+			m.Value = int32(v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Outer_NamedEntry struct {
+	Key   string
+	Value *Outer_Inner
+}
+
+// Serialize marshals Outer_NamedEntry to the protobuf wire format.
+func (m *Outer_NamedEntry) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.Key; v != "" {
+		b.EncodeTag(1, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := m.Value; v != nil {
+		b.EncodeTag(2, wire.Bytes)
+		if err := b.EncodeMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals Outer_NamedEntry from the protobuf wire format.
+func (m *Outer_NamedEntry) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Key, err = b.DecodeString()
+		case 2:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			v := &Outer_Inner{}
+			err = b.DecodeMessage(v)
+			m.Value = v
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Scalars struct {
+	DoubleValue   float64
+	FloatValue    float32
+	Int64Value    int64
+	Uint64Value   uint64
+	Int32Value    int32
+	Uint32Value   uint32
+	Sint32Value   int32
+	Sint64Value   int64
+	Fixed32Value  uint32
+	Sfixed32Value int32
+	Fixed64Value  uint64
+	Sfixed64Value int64
+	BoolValue     bool
+	StringValue   string
+	BytesValue    []byte
+	Int32Values   []int32
+	Sint64Values  []int64
+	StringValues  []string
+}
+
+// Serialize marshals Scalars to the protobuf wire format.
+func (m *Scalars) Serialize() ([]byte, error) {
+	b := wire.NewBuffer(nil)
+	if v := m.DoubleValue; v != 0 {
+		b.EncodeTag(1, wire.Fixed64)
+		b.EncodeDouble(v)
+	}
+	if v := m.FloatValue; v != 0 {
+		b.EncodeTag(2, wire.Fixed32)
+		b.EncodeFloat(v)
+	}
+	if v := m.Int64Value; v != 0 {
+		b.EncodeTag(3, wire.Varint)
+		b.EncodeVarint(uint64(v))
+	}
+	if v := m.Uint64Value; v != 0 {
+		b.EncodeTag(4, wire.Varint)
+		b.EncodeVarint(v)
+	}
+	if v := m.Int32Value; v != 0 {
+		b.EncodeTag(5, wire.Varint)
+		b.EncodeVarint(uint64(v))
+	}
+	if v := m.Uint32Value; v != 0 {
+		b.EncodeTag(6, wire.Varint)
+		b.EncodeVarint(uint64(v))
+	}
+	if v := m.Sint32Value; v != 0 {
+		b.EncodeTag(7, wire.Varint)
+		b.EncodeZigzag(int64(v))
+	}
+	if v := m.Sint64Value; v != 0 {
+		b.EncodeTag(8, wire.Varint)
+		b.EncodeZigzag(v)
+	}
+	if v := m.Fixed32Value; v != 0 {
+		b.EncodeTag(9, wire.Fixed32)
+		b.EncodeFixed32(v)
+	}
+	if v := m.Sfixed32Value; v != 0 {
+		b.EncodeTag(10, wire.Fixed32)
+		b.EncodeFixed32(uint32(v))
+	}
+	if v := m.Fixed64Value; v != 0 {
+		b.EncodeTag(11, wire.Fixed64)
+		b.EncodeFixed64(v)
+	}
+	if v := m.Sfixed64Value; v != 0 {
+		b.EncodeTag(12, wire.Fixed64)
+		b.EncodeFixed64(uint64(v))
+	}
+	if v := m.BoolValue; v {
+		b.EncodeTag(13, wire.Varint)
+		b.EncodeBool(v)
+	}
+	if v := m.StringValue; v != "" {
+		b.EncodeTag(14, wire.Bytes)
+		b.EncodeString(v)
+	}
+	if v := m.BytesValue; len(v) > 0 {
+		b.EncodeTag(15, wire.Bytes)
+		b.EncodeBytes(v)
+	}
+	if len(m.Int32Values) > 0 {
+		p := wire.NewBuffer(nil)
+		for _, v := range m.Int32Values {
+			p.EncodeVarint(uint64(v))
+		}
+		b.EncodeTag(16, wire.Bytes)
+		b.EncodeBytes(p.Bytes())
+	}
+	if len(m.Sint64Values) > 0 {
+		p := wire.NewBuffer(nil)
+		for _, v := range m.Sint64Values {
+			p.EncodeZigzag(v)
+		}
+		b.EncodeTag(17, wire.Bytes)
+		b.EncodeBytes(p.Bytes())
+	}
+	for _, v := range m.StringValues {
+		b.EncodeTag(18, wire.Bytes)
+		b.EncodeString(v)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Deserialize unmarshals Scalars from the protobuf wire format.
+func (m *Scalars) Deserialize(data []byte) error {
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			if typ != wire.Fixed64 {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.DoubleValue, err = b.DecodeDouble()
+		case 2:
+			if typ != wire.Fixed32 {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.FloatValue, err = b.DecodeFloat()
+		case 3:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeVarint()
+			m.Int64Value = int64(v)
+		case 4:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Uint64Value, err = b.DecodeVarint()
+		case 5:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeVarint()
+			m.Int32Value = int32(v)
+		case 6:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeVarint()
+			m.Uint32Value = uint32(v)
+		case 7:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v int64
+			v, err = b.DecodeZigzag()
+			m.Sint32Value = int32(v)
+		case 8:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Sint64Value, err = b.DecodeZigzag()
+		case 9:
+			if typ != wire.Fixed32 {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Fixed32Value, err = b.DecodeFixed32()
+		case 10:
+			if typ != wire.Fixed32 {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint32
+			v, err = b.DecodeFixed32()
+			m.Sfixed32Value = int32(v)
+		case 11:
+			if typ != wire.Fixed64 {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.Fixed64Value, err = b.DecodeFixed64()
+		case 12:
+			if typ != wire.Fixed64 {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeFixed64()
+			m.Sfixed64Value = int64(v)
+		case 13:
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.BoolValue, err = b.DecodeBool()
+		case 14:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.StringValue, err = b.DecodeString()
+		case 15:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			m.BytesValue, err = b.DecodeBytes()
+		case 16:
+			if typ == wire.Bytes {
+				err = b.DecodePacked(func(b *wire.Buffer) error {
+					v, err := b.DecodeVarint()
+					m.Int32Values = append(m.Int32Values, int32(v))
+					return err
+				})
+				break
+			}
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v uint64
+			v, err = b.DecodeVarint()
+			m.Int32Values = append(m.Int32Values, int32(v))
+		case 17:
+			if typ == wire.Bytes {
+				err = b.DecodePacked(func(b *wire.Buffer) error {
+					v, err := b.DecodeZigzag()
+					m.Sint64Values = append(m.Sint64Values, v)
+					return err
+				})
+				break
+			}
+			if typ != wire.Varint {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v int64
+			v, err = b.DecodeZigzag()
+			m.Sint64Values = append(m.Sint64Values, v)
+		case 18:
+			if typ != wire.Bytes {
+				err = wire.ErrUnexpectedWireType
+				break
+			}
+			var v string
+			v, err = b.DecodeString()
+			m.StringValues = append(m.StringValues, v)
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MyServiceClient is a client for the test.MyService service.
+type MyServiceClient interface {
+	Unary(req *MyMessage, opts ...grpcweb.CallOption) (*MyMessage, error)
+	ServerStream(req *MyMessage, opts ...grpcweb.CallOption) (MyService_ServerStreamClient, error)
+}
+
+type myServiceClient struct {
+	client grpcweb.Client
+}
+
+// NewMyServiceClient creates a new MyServiceClient making calls
+// with the provided client, usually a *grpcweb.ClientConn.
+func NewMyServiceClient(client grpcweb.Client) MyServiceClient {
+	return &myServiceClient{client: client}
+}
+
+// Unary calls the Unary method of the test.MyService service.
+func (c *myServiceClient) Unary(req *MyMessage, opts ...grpcweb.CallOption) (*MyMessage, error) {
+	resp, err := c.client.RPCCall("/test.MyService/Unary", req, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &MyMessage{}
+	if err = out.Deserialize(resp); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ServerStream calls the ServerStream method of the test.MyService service.
+func (c *myServiceClient) ServerStream(req *MyMessage, opts ...grpcweb.CallOption) (MyService_ServerStreamClient, error) {
+	stream, err := c.client.ServerStreaming("/test.MyService/ServerStream", req, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &myServiceServerStreamClient{ClientStream: stream}, nil
+}
+
+// MyService_ServerStreamClient receives the responses of the ServerStream method.
+type MyService_ServerStreamClient interface {
+	Recv() (*MyMessage, error)
+	Close()
+}
+
+type myServiceServerStreamClient struct {
+	grpcweb.ClientStream
+}
+
+func (x *myServiceServerStreamClient) Recv() (*MyMessage, error) {
+	resp, err := x.ClientStream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &MyMessage{}
+	if err = out.Deserialize(resp); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// MockMyServiceClient is a MyServiceClient for use in tests. Each method calls
+// the func field of the same name with a Func suffix, or
+// returns an Unimplemented error if the field is nil.
+type MockMyServiceClient struct {
+	UnaryFunc        func(req *MyMessage, opts ...grpcweb.CallOption) (*MyMessage, error)
+	ServerStreamFunc func(req *MyMessage, opts ...grpcweb.CallOption) (MyService_ServerStreamClient, error)
+}
+
+// Unary calls UnaryFunc.
+func (m *MockMyServiceClient) Unary(req *MyMessage, opts ...grpcweb.CallOption) (*MyMessage, error) {
+	if m.UnaryFunc == nil {
+		return nil, &grpcweb.Error{Code: grpcweb.Unimplemented, Message: "MockMyServiceClient.UnaryFunc is not set"}
+	}
+
+	return m.UnaryFunc(req, opts...)
+}
+
+// ServerStream calls ServerStreamFunc.
+func (m *MockMyServiceClient) ServerStream(req *MyMessage, opts ...grpcweb.CallOption) (MyService_ServerStreamClient, error) {
+	if m.ServerStreamFunc == nil {
+		return nil, &grpcweb.Error{Code: grpcweb.Unimplemented, Message: "MockMyServiceClient.ServerStreamFunc is not set"}
+	}
+
+	return m.ServerStreamFunc(req, opts...)
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PushbackTrailer is the trailer a server can use to tell the client
+// how many milliseconds to wait before retrying a call. A negative or
+// malformed value tells the client not to retry the call.
+const PushbackTrailer = "grpc-retry-pushback-ms"
+
+// RetryPolicy configures the automatic retrying of failed calls.
+// Unary calls are retried transparently. Server streams are retried
+// only until the first message has been received.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts,
+	// including the original call.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	// A value of 0 means no cap.
+	MaxBackoff time.Duration
+	// BackoffMultiplier is applied to the delay after each attempt.
+	// Values less than 1 are treated as 1.
+	BackoffMultiplier float64
+	// Jitter randomly varies each delay by up to this fraction
+	// of the delay, in either direction. Values outside of 0 and 1
+	// are clamped to that range.
+	Jitter float64
+	// RetryableStatusCodes are the status codes that are retried.
+	RetryableStatusCodes []StatusCode
+}
+
+// WithRetryPolicy sets the retry policy used by all methods
+// that don't have a policy set with WithMethodRetryPolicy.
+func WithRetryPolicy(policy *RetryPolicy) ClientOption {
+	return func(c *clientBase) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithMethodRetryPolicy sets the retry policy of the method at the
+// provided endpoint. A nil policy disables retries for the method.
+func WithMethodRetryPolicy(endpoint string, policy *RetryPolicy) ClientOption {
+	return func(c *clientBase) {
+		if c.methodRetryPolicies == nil {
+			c.methodRetryPolicies = map[string]*RetryPolicy{}
+		}
+		c.methodRetryPolicies[endpoint] = policy
+	}
+}
+
+func (c *clientBase) retryPolicyFor(endpoint string) *RetryPolicy {
+	if policy, ok := c.methodRetryPolicies[endpoint]; ok {
+		return policy
+	}
+
+	return c.retryPolicy
+}
+
+// backoff returns how long to wait before retrying a call whose
+// attempt number attempt failed with err and trailer. It returns
+// false if the call should not be retried.
+func (p *RetryPolicy) backoff(attempt int, err error, trailer Metadata) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	var e *Error
+	if !errors.As(err, &e) || !p.retryable(e.Code) {
+		return 0, false
+	}
+
+	if pushback := trailer.Get(PushbackTrailer); len(pushback) > 0 {
+		ms, err := strconv.Atoi(pushback[0])
+		if err != nil || ms < 0 {
+			return 0, false
+		}
+		return time.Duration(ms) * time.Millisecond, true
+	}
+
+	multiplier := math.Max(p.BackoffMultiplier, 1)
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		delay = math.Min(delay, float64(p.MaxBackoff))
+	}
+	if jitter := math.Min(p.Jitter, 1); jitter > 0 {
+		delay *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	// Converting a delay that doesn't fit in a Duration,
+	// such as +Inf, is undefined
+	if delay >= math.MaxInt64 {
+		return math.MaxInt64, true
+	}
+
+	return time.Duration(delay), true
+}
+
+func (p *RetryPolicy) retryable(code StatusCode) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryStream is a ClientStream that retries the stream
+// until the first message has been received.
+type retryStream struct {
+	policy    *RetryPolicy
+	newStream func() (*StreamReader, error)
+	// refresh refreshes the credentials of the client, and reports
+	// whether the stream should be retried with the new credentials.
+	refresh func(creds Metadata, err error) bool
+
+	mu        sync.Mutex
+	cur       *StreamReader
+	attempt   int
+	committed bool
+	closed    bool
+	refreshed bool
+}
+
+func (r *retryStream) Recv() ([]byte, error) {
+	r.mu.Lock()
+	cur := r.cur
+	r.mu.Unlock()
+
+	for {
+		msg, err := cur.Recv()
+		if err == nil {
+			r.mu.Lock()
+			r.committed = true
+			r.mu.Unlock()
+			return msg, nil
+		}
+
+		r.mu.Lock()
+		if r.committed || r.closed {
+			r.mu.Unlock()
+			return nil, err
+		}
+		var delay time.Duration
+		if !r.refreshed && r.refresh(cur.creds, err) {
+			r.refreshed = true
+		} else {
+			r.attempt++
+			var ok bool
+			delay, ok = r.policy.backoff(r.attempt, err, cur.trailer)
+			if !ok {
+				r.mu.Unlock()
+				return nil, err
+			}
+		}
+		r.mu.Unlock()
+
+		time.Sleep(delay)
+
+		next, nerr := r.newStream()
+		if nerr != nil {
+			return nil, nerr
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			next.Close()
+			return nil, err
+		}
+		r.cur, cur = next, next
+		r.mu.Unlock()
+	}
+}
+
+func (r *retryStream) Close() {
+	r.mu.Lock()
+	r.closed = true
+	cur := r.cur
+	r.mu.Unlock()
+
+	cur.Close()
+}
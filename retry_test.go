@@ -0,0 +1,259 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	grpcweb "github.com/johanbrandhorst/gopherjs-grpc-web"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
+)
+
+var errUnavailable = &grpcweb.Error{Code: grpcweb.Unavailable, Message: "unavailable"}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &grpcweb.RetryPolicy{
+		MaxAttempts:          5,
+		InitialBackoff:       100 * time.Millisecond,
+		MaxBackoff:           time.Second,
+		BackoffMultiplier:    3,
+		RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable},
+	}
+	for attempt, want := range []time.Duration{
+		1: 100 * time.Millisecond,
+		2: 300 * time.Millisecond,
+		3: 900 * time.Millisecond,
+		4: time.Second,
+	} {
+		if attempt == 0 {
+			continue
+		}
+		got, ok := policy.Backoff(attempt, errUnavailable, nil)
+		if !ok || got != want {
+			t.Errorf("attempt %d: got %v, %t, want %v, true", attempt, got, ok, want)
+		}
+	}
+	if _, ok := policy.Backoff(5, errUnavailable, nil); ok {
+		t.Error("the call was retried after MaxAttempts attempts")
+	}
+}
+
+func TestRetryPolicyBackoffLimits(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		policy   *grpcweb.RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{
+			name: "multiplier below 1",
+			policy: &grpcweb.RetryPolicy{
+				InitialBackoff:    time.Second,
+				BackoffMultiplier: 0.5,
+			},
+			attempt: 3,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name: "jitter",
+			policy: &grpcweb.RetryPolicy{
+				InitialBackoff: time.Second,
+				Jitter:         0.2,
+			},
+			attempt: 1,
+			min:     800 * time.Millisecond,
+			max:     1200 * time.Millisecond,
+		},
+		{
+			name: "jitter above 1",
+			policy: &grpcweb.RetryPolicy{
+				InitialBackoff: time.Second,
+				Jitter:         5,
+			},
+			attempt: 1,
+			min:     0,
+			max:     2 * time.Second,
+		},
+		{
+			name: "negative jitter",
+			policy: &grpcweb.RetryPolicy{
+				InitialBackoff: time.Second,
+				Jitter:         -5,
+			},
+			attempt: 1,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name: "no maximum",
+			policy: &grpcweb.RetryPolicy{
+				InitialBackoff:    time.Second,
+				BackoffMultiplier: 10,
+			},
+			attempt: 1000,
+			min:     math.MaxInt64,
+			max:     math.MaxInt64,
+		},
+		{
+			name: "no maximum with jitter",
+			policy: &grpcweb.RetryPolicy{
+				InitialBackoff:    time.Second,
+				BackoffMultiplier: 10,
+				Jitter:            1,
+			},
+			attempt: 1000,
+			min:     0,
+			max:     math.MaxInt64,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.MaxAttempts = tt.attempt + 1
+			tt.policy.RetryableStatusCodes = []grpcweb.StatusCode{grpcweb.Unavailable}
+			for i := 0; i < 100; i++ {
+				got, ok := tt.policy.Backoff(tt.attempt, errUnavailable, nil)
+				if !ok || got < tt.min || got > tt.max {
+					t.Fatalf("got %v, %t, want between %v and %v", got, ok, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyPushback(t *testing.T) {
+	policy := &grpcweb.RetryPolicy{
+		MaxAttempts:          2,
+		InitialBackoff:       time.Second,
+		RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable},
+	}
+	for _, tt := range []struct {
+		pushback string
+		want     time.Duration
+		retry    bool
+	}{
+		{"250", 250 * time.Millisecond, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	} {
+		trailer := metadata.Pairs(grpcweb.PushbackTrailer, tt.pushback)
+		got, ok := policy.Backoff(1, errUnavailable, trailer)
+		if got != tt.want || ok != tt.retry {
+			t.Errorf("pushback %q: got %v, %t, want %v, %t", tt.pushback, got, ok, tt.want, tt.retry)
+		}
+	}
+}
+
+func TestRetryPolicyRetryableCodes(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method,
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unavailable}},
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.ResourceExhausted}},
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Internal}},
+		&grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("resp")}},
+	)
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithRetryPolicy(&grpcweb.RetryPolicy{
+			MaxAttempts:          5,
+			InitialBackoff:       time.Millisecond,
+			RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable, grpcweb.ResourceExhausted},
+		}),
+	)
+
+	// Internal isn't retryable, so the fourth response is never used
+	_, err := c.RPCCall(method, message("req"))
+	if e, ok := err.(*grpcweb.Error); !ok || e.Code != grpcweb.Internal {
+		t.Errorf("got %v, want an Internal error", err)
+	}
+	if n := len(transport.Requests()); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestRetryPolicyPerMethod(t *testing.T) {
+	const other = "/test.Service/Other"
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method, &grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unavailable}})
+	transport.Script(other, &grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unavailable}})
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithRetryPolicy(&grpcweb.RetryPolicy{
+			MaxAttempts:          3,
+			InitialBackoff:       time.Millisecond,
+			RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable},
+		}),
+		grpcweb.WithMethodRetryPolicy(other, nil),
+	)
+
+	if _, err := c.RPCCall(method, message("req")); err == nil {
+		t.Fatal("RPCCall succeeded")
+	}
+	if _, err := c.RPCCall(other, message("req")); err == nil {
+		t.Fatal("RPCCall succeeded")
+	}
+	if n := len(transport.Requests()); n != 4 {
+		t.Errorf("got %d requests, want 3 for the client policy and 1 for the disabled method", n)
+	}
+}
+
+func TestRetryStreamCommit(t *testing.T) {
+	transport := grpcweb.NewFakeTransport()
+	transport.Script(method,
+		&grpcweb.FakeResponse{Status: &grpcweb.Status{Code: grpcweb.Unavailable}},
+		&grpcweb.FakeResponse{
+			Messages: []grpcweb.ProtoMessage{message("one")},
+			Status:   &grpcweb.Status{Code: grpcweb.Unavailable},
+		},
+		&grpcweb.FakeResponse{Messages: []grpcweb.ProtoMessage{message("unused")}},
+	)
+	c := grpcweb.NewGRPCWebClientBase(
+		grpcweb.WithTransport(transport),
+		grpcweb.WithRetryPolicy(&grpcweb.RetryPolicy{
+			MaxAttempts:          5,
+			InitialBackoff:       time.Millisecond,
+			RetryableStatusCodes: []grpcweb.StatusCode{grpcweb.Unavailable},
+		}),
+	)
+
+	stream, err := c.ServerStreaming(method, message("req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// The failure before the first message is retried
+	msg, err := stream.Recv()
+	if err != nil || string(msg) != "one" {
+		t.Fatalf("got %q, %v, want %q", msg, err, "one")
+	}
+	// The failure after it is returned
+	_, err = stream.Recv()
+	if e, ok := err.(*grpcweb.Error); !ok || e.Code != grpcweb.Unavailable {
+		t.Errorf("got %v after the first message, want an Unavailable error", err)
+	}
+	if n := len(transport.Requests()); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
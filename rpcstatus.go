@@ -0,0 +1,140 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+// ErrUnexpectedWireType is the cause of a StatusParseError
+// for a field of the status encoded with the wrong wire type.
+var ErrUnexpectedWireType = errors.New("unexpected wire type")
+
+// StatusParseError is returned by ParseRPCStatus
+// for bytes that are not a valid google.rpc.Status.
+type StatusParseError struct {
+	// Message is the name of the message being decoded,
+	// google.rpc.Status or one of the messages in it.
+	Message string
+	// Field is the number of the field being decoded,
+	// or 0 if the error occurred decoding a tag.
+	Field int32
+	// Err is the cause of the error, such as wire.ErrTruncated.
+	Err error
+}
+
+func (e *StatusParseError) Error() string {
+	msg := "grpcweb: malformed " + e.Message
+	if e.Field != 0 {
+		msg += " field " + strconv.Itoa(int(e.Field))
+	}
+
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *StatusParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseRPCStatus parses the raw bytes of a google.rpc.Status, as sent
+// by the gRPC-gateway, to a Status. The grpc.gateway.Pair details of
+// the status become the Metadata of the Status, and the remaining
+// details the Details of the Status. Malformed input is reported
+// with a *StatusParseError.
+func ParseRPCStatus(rawBytes []byte) (*Status, error) {
+	const name = "google.rpc.Status"
+
+	s := &Status{Metadata: Metadata{}}
+	b := wire.NewBuffer(rawBytes)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return nil, &StatusParseError{Message: name, Err: err}
+		}
+
+		switch {
+		case field == 1 && typ == wire.Varint:
+			var v uint64
+			v, err = b.DecodeVarint()
+			s.Code = wireStatusCode(int64(int32(v)))
+		case field == 2 && typ == wire.Bytes:
+			s.Message, err = b.DecodeString()
+		case field == 3 && typ == wire.Bytes:
+			var a Any
+			if err = b.DecodeMessage(&a); err != nil {
+				break
+			}
+			if a.MessageName() != pairMessageName {
+				s.details = append(s.details, a)
+				break
+			}
+			if err := decodePair(a.Value, s.Metadata); err != nil {
+				return nil, err
+			}
+		case field >= 1 && field <= 3:
+			err = ErrUnexpectedWireType
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return nil, &StatusParseError{Message: name, Field: field, Err: err}
+		}
+	}
+
+	return s, nil
+}
+
+// pairMessageName is the name of the grpc.gateway.Pair messages
+// the gRPC-gateway sends metadata as in the details of a status.
+const pairMessageName = "grpc.gateway.Pair"
+
+// decodePair decodes a grpc.gateway.Pair, adding its value to md.
+func decodePair(data []byte, md Metadata) error {
+	var key, value string
+	b := wire.NewBuffer(data)
+	for !b.Done() {
+		field, typ, err := b.DecodeTag()
+		if err != nil {
+			return &StatusParseError{Message: pairMessageName, Err: err}
+		}
+
+		switch {
+		case field == 1 && typ == wire.Bytes:
+			key, err = b.DecodeString()
+		case field == 2 && typ == wire.Bytes:
+			value, err = b.DecodeString()
+		case field == 1 || field == 2:
+			err = ErrUnexpectedWireType
+		default:
+			err = b.Skip(field, typ)
+		}
+		if err != nil {
+			return &StatusParseError{Message: pairMessageName, Field: field, Err: err}
+		}
+	}
+	md.Append(key, value)
+
+	return nil
+}
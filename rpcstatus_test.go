@@ -0,0 +1,188 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/johanbrandhorst/gopherjs-grpc-web/wire"
+)
+
+// encodeTestStatus encodes a google.rpc.Status with the code,
+// message and details, and a grpc.gateway.Pair for each pair.
+func encodeTestStatus(code StatusCode, message string, details []Any, pairs ...[2]string) []byte {
+	for _, p := range pairs {
+		pair := wire.NewBuffer(nil)
+		pair.EncodeTag(1, wire.Bytes)
+		pair.EncodeString(p[0])
+		pair.EncodeTag(2, wire.Bytes)
+		pair.EncodeString(p[1])
+		details = append(details, Any{TypeURL: "type.googleapis.com/" + pairMessageName, Value: pair.Bytes()})
+	}
+
+	b := wire.NewBuffer(nil)
+	b.EncodeTag(1, wire.Varint)
+	b.EncodeVarint(uint64(code))
+	b.EncodeTag(2, wire.Bytes)
+	b.EncodeString(message)
+	for i := range details {
+		b.EncodeTag(3, wire.Bytes)
+		b.EncodeMessage(&details[i])
+	}
+
+	return b.Bytes()
+}
+
+// withDetail returns the encoding of a status with a single detail
+func withDetail(detail []byte) []byte {
+	b := wire.NewBuffer(nil)
+	b.EncodeTag(3, wire.Bytes)
+	b.EncodeBytes(detail)
+	return b.Bytes()
+}
+
+// pairDetail returns the encoding of an Any holding a grpc.gateway.Pair
+func pairDetail(pair []byte) []byte {
+	b, _ := (&Any{TypeURL: "type.googleapis.com/" + pairMessageName, Value: pair}).Serialize()
+	return b
+}
+
+func TestParseRPCStatus(t *testing.T) {
+	detail := Any{TypeURL: "type.googleapis.com/test.Detail", Value: []byte{0x08, 0x01}}
+	raw := encodeTestStatus(NotFound, "no such thing", []Any{detail}, [2]string{"key", "value"})
+	// An unknown field is skipped
+	raw = append(raw, 0x20, 0x01)
+
+	st, err := ParseRPCStatus(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Code != NotFound || st.Message != "no such thing" {
+		t.Errorf("got status %v %q, want NotFound %q", st.Code, st.Message, "no such thing")
+	}
+	if d := st.Details(); len(d) != 1 || d[0].TypeURL != detail.TypeURL || string(d[0].Value) != string(detail.Value) {
+		t.Errorf("got details %v, want %v", d, detail)
+	}
+	if v := st.Metadata.Get("key"); len(v) != 1 || v[0] != "value" {
+		t.Errorf("got metadata %v, want the grpc.gateway.Pair", st.Metadata)
+	}
+
+	st, err = ParseRPCStatus(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Code != Ok || st.Message != "" || len(st.Details()) != 0 {
+		t.Errorf("got status %v %q %v for empty input, want Ok", st.Code, st.Message, st.Details())
+	}
+}
+
+func TestParseRPCStatusCodes(t *testing.T) {
+	for _, tt := range []struct {
+		code StatusCode
+		want StatusCode
+	}{
+		{Ok, Ok},
+		{Unauthenticated, Unauthenticated},
+		// Codes not defined by gRPC are Unknown
+		{17, Unknown},
+		{1000, Unknown},
+		{-1, Unknown},
+	} {
+		st, err := ParseRPCStatus(encodeTestStatus(tt.code, "", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Code != tt.want {
+			t.Errorf("code %d: got %v, want %v", int(tt.code), st.Code, tt.want)
+		}
+	}
+}
+
+func TestParseRPCStatusErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		raw     []byte
+		message string
+		field   int32
+		err     error
+	}{
+		{"truncated tag", []byte{0x88}, "google.rpc.Status", 0, wire.ErrTruncated},
+		{"truncated code", []byte{0x08, 0x80}, "google.rpc.Status", 1, wire.ErrTruncated},
+		{"overflowing code", []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "google.rpc.Status", 1, wire.ErrOverflow},
+		{"truncated message length", []byte{0x12, 0x80}, "google.rpc.Status", 2, wire.ErrTruncated},
+		{"invalid wire type", []byte{0x0f}, "google.rpc.Status", 0, wire.ErrWireType},
+		{"code with bytes wire type", []byte{0x0a, 0x00}, "google.rpc.Status", 1, ErrUnexpectedWireType},
+		{"message with varint wire type", []byte{0x10, 0x01}, "google.rpc.Status", 2, ErrUnexpectedWireType},
+		{"details with fixed32 wire type", []byte{0x1d, 0x00, 0x00, 0x00, 0x00}, "google.rpc.Status", 3, ErrUnexpectedWireType},
+		{"over-long message", []byte{0x12, 0x05, 'a'}, "google.rpc.Status", 2, wire.ErrTruncated},
+		{"huge message length", []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, "google.rpc.Status", 2, wire.ErrTruncated},
+		{"over-long details", []byte{0x1a, 0x03, 0x0a}, "google.rpc.Status", 3, wire.ErrTruncated},
+		{"over-long detail type URL", withDetail([]byte{0x0a, 0x05, 'a'}), "google.rpc.Status", 3, wire.ErrTruncated},
+		{"truncated unknown field", []byte{0x22, 0x05}, "google.rpc.Status", 4, wire.ErrTruncated},
+		{"truncated pair tag", withDetail(pairDetail([]byte{0x80})), "grpc.gateway.Pair", 0, wire.ErrTruncated},
+		{"over-long pair key", withDetail(pairDetail([]byte{0x0a, 0x09, 'k'})), "grpc.gateway.Pair", 1, wire.ErrTruncated},
+		{"over-long pair value", withDetail(pairDetail([]byte{0x0a, 0x01, 'k', 0x12, 0x09, 'v'})), "grpc.gateway.Pair", 2, wire.ErrTruncated},
+		{"pair key with varint wire type", withDetail(pairDetail([]byte{0x08, 0x01})), "grpc.gateway.Pair", 1, ErrUnexpectedWireType},
+		{"pair value with fixed64 wire type", withDetail(pairDetail([]byte{0x11, 0, 0, 0, 0, 0, 0, 0, 0})), "grpc.gateway.Pair", 2, ErrUnexpectedWireType},
+		{"pair with invalid wire type", withDetail(pairDetail([]byte{0x0e})), "grpc.gateway.Pair", 0, wire.ErrWireType},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := ParseRPCStatus(tt.raw)
+			if err == nil {
+				t.Fatalf("got status %v, want an error", st)
+			}
+			var pe *StatusParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got error %v, want a *StatusParseError", err)
+			}
+			if pe.Message != tt.message || pe.Field != tt.field {
+				t.Errorf("got error in %s field %d, want %s field %d", pe.Message, pe.Field, tt.message, tt.field)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func FuzzParseRPCStatus(f *testing.F) {
+	f.Add(encodeTestStatus(NotFound, "no such thing",
+		[]Any{{TypeURL: "type.googleapis.com/test.Detail", Value: []byte{0x08, 0x01}}},
+		[2]string{"key", "value"},
+	))
+	f.Add([]byte{})
+	f.Add(withDetail(pairDetail([]byte{0x0a, 0x09, 'k'})))
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		st, err := ParseRPCStatus(raw)
+		if err != nil {
+			var pe *StatusParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got error %v, want a *StatusParseError", err)
+			}
+			return
+		}
+		if st == nil || st.Metadata == nil {
+			t.Fatalf("got status %v without an error", st)
+		}
+	})
+}
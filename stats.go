@@ -0,0 +1,160 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"time"
+)
+
+// StatsHandler receives the events of the calls made by a client,
+// for collecting metrics. Each attempt of a retried call is reported
+// as a separate call. The methods are called from the goroutines
+// making and reading the calls, so they must be safe for concurrent
+// use and should not block.
+type StatsHandler interface {
+	// Begin is called when a call starts. The RPCInfo identifies
+	// the call in the subsequent events.
+	Begin(info *RPCInfo)
+	// OutHeader is called with the headers of the request.
+	OutHeader(info *RPCInfo, header Metadata)
+	// OutPayload is called for each request message sent.
+	OutPayload(info *RPCInfo, p *PayloadStats)
+	// InHeader is called with the headers of the response.
+	InHeader(info *RPCInfo, header Metadata)
+	// InPayload is called for each response message received.
+	InPayload(info *RPCInfo, p *PayloadStats)
+	// End is called once the call has finished.
+	End(info *RPCInfo, end *EndStats)
+}
+
+// WithStatsHandler sets the StatsHandler receiving
+// the events of the calls made by the client.
+func WithStatsHandler(h StatsHandler) ClientOption {
+	return func(c *clientBase) {
+		c.statsHandler = h
+	}
+}
+
+// RPCInfo describes a call reported to a StatsHandler.
+type RPCInfo struct {
+	// Method is the method called, in the
+	// form "/package.Service/Method".
+	Method string
+	// ClientStream and ServerStream report whether the
+	// request and response are streams of messages.
+	ClientStream bool
+	ServerStream bool
+	// BeginTime is the time the call started.
+	BeginTime time.Time
+}
+
+// PayloadStats describes a message sent or received.
+type PayloadStats struct {
+	// Length is the size of the serialized message, in bytes.
+	Length int
+	// WireLength is the size of a received message before it was
+	// decompressed. Sent messages are compressed by the Transport,
+	// so their WireLength is their Length.
+	WireLength int
+	// Time is the time the message was sent or received.
+	Time time.Time
+}
+
+// EndStats describes how a call finished.
+type EndStats struct {
+	// Code is the status code of the call.
+	Code StatusCode
+	// Error is the error the call failed with,
+	// or nil if it finished with Ok.
+	Error error
+	// Trailer holds the trailers received with the status.
+	Trailer Metadata
+	// EndTime is the time the call finished.
+	EndTime time.Time
+}
+
+// callStats reports the events of a call to a StatsHandler.
+// Its methods do nothing on a nil *callStats, which is used
+// by clients without a StatsHandler.
+type callStats struct {
+	handler StatsHandler
+	info    *RPCInfo
+}
+
+// beginStats reports the start of a call to the StatsHandler
+// of the client. It returns nil if there is none.
+func (c *clientBase) beginStats(endpoint string, clientStream, serverStream bool) *callStats {
+	if c.statsHandler == nil {
+		return nil
+	}
+
+	s := &callStats{
+		handler: c.statsHandler,
+		info: &RPCInfo{
+			Method:       endpoint,
+			ClientStream: clientStream,
+			ServerStream: serverStream,
+			BeginTime:    time.Now(),
+		},
+	}
+	s.handler.Begin(s.info)
+
+	return s
+}
+
+func (s *callStats) outHeader(header Metadata) {
+	if s != nil {
+		s.handler.OutHeader(s.info, header)
+	}
+}
+
+func (s *callStats) outPayload(length, wireLength int) {
+	if s != nil {
+		s.handler.OutPayload(s.info, &PayloadStats{Length: length, WireLength: wireLength, Time: time.Now()})
+	}
+}
+
+func (s *callStats) inHeader(header Metadata) {
+	if s != nil {
+		s.handler.InHeader(s.info, header)
+	}
+}
+
+func (s *callStats) inPayload(length, wireLength int) {
+	if s != nil {
+		s.handler.InPayload(s.info, &PayloadStats{Length: length, WireLength: wireLength, Time: time.Now()})
+	}
+}
+
+// end reports the end of the call with err, which
+// is EOF if the call finished with Ok.
+func (s *callStats) end(err error, trailer Metadata) {
+	if s == nil {
+		return
+	}
+
+	end := &EndStats{Code: Ok, Trailer: trailer, EndTime: time.Now()}
+	if err != EOF {
+		end.Code = errorCode(err)
+		end.Error = err
+	}
+	s.handler.End(s.info, end)
+}
@@ -21,26 +21,43 @@
 package grpcweb
 
 import (
-	"github.com/gopherjs/gopherjs/js"
+	"errors"
+	"strconv"
+	"strings"
 
-	// Include gRPC-web JS objects
-	_ "github.com/johanbrandhorst/gopherjs-grpc-web/grpcwebjs"
+	"github.com/johanbrandhorst/gopherjs-grpc-web/metadata"
 )
 
-// Metadata is a simple string to string map.
-type Metadata map[string]string
+// Metadata is a mapping from lowercase metadata keys to their values.
+// It is the metadata.MD type of the metadata package.
+type Metadata = metadata.MD
 
 // Status is a gRPC-web Status.
 type Status struct {
-	*js.Object
-	Code     StatusCode `js:"code"`
-	Details  string     `js:"details"`
-	Metadata Metadata   `js:"metadata"`
+	Code     StatusCode
+	Message  string
+	Metadata Metadata
+
+	details []Any
+}
+
+// Details returns the details of the status, the messages sent
+// by the server in the details of a google.rpc.Status.
+func (s *Status) Details() []Any {
+	return s.details
+}
+
+// WithDetails returns a copy of s with the details added.
+func (s *Status) WithDetails(details ...Any) *Status {
+	out := *s
+	out.details = append(append([]Any(nil), s.details...), details...)
+	return &out
 }
 
 // StatusCode is a gRPC-web StatusCode.
 type StatusCode int
 
+// The StatusCodes have the values of the gRPC status codes.
 const (
 	// Ok is Not an error; returned on success.
 	Ok = StatusCode(iota)
@@ -81,10 +98,6 @@ const (
 	// (use Unautheticated instead for those errors).
 	PermissionDenied
 
-	// Unauthenticated is returned when the request does not have valid
-	// authentication credentials for the operation.
-	Unauthenticated
-
 	// ResourceExhausted is returned when some resource has been exhausted,
 	// perhaps a per-user quota, or perhaps the entire file system is out of space.
 	ResourceExhausted
@@ -149,9 +162,138 @@ const (
 
 	// DataLoss indicates unrecoverable data loss or corruption.
 	DataLoss
+
+	// Unauthenticated is returned when the request does not have valid
+	// authentication credentials for the operation.
+	Unauthenticated
 )
 
-// FromHTTPStatus converts a HTTP Status code to a StatusCode
+// FromHTTPStatus converts a HTTP Status code to a StatusCode, for
+// responses without a gRPC status. It follows the gRPC HTTP to gRPC
+// status code mapping, and maps 200 OK to Ok.
 func FromHTTPStatus(HTTPCode int) StatusCode {
-	return StatusCode(js.Global.Get("grpc").Get("web").Get("StatusCode").Call("fromHttpStatus", HTTPCode).Int())
+	switch HTTPCode {
+	case 200:
+		return Ok
+	case 400:
+		return Internal
+	case 401:
+		return Unauthenticated
+	case 403:
+		return PermissionDenied
+	case 404:
+		return Unimplemented
+	case 429, 502, 503, 504:
+		return Unavailable
+	default:
+		return Unknown
+	}
+}
+
+// statusCodeNames holds the name of each StatusCode,
+// and its name in the gRPC specification.
+var statusCodeNames = [...]struct{ name, specName string }{
+	Ok:                 {"Ok", "OK"},
+	Cancelled:          {"Cancelled", "CANCELLED"},
+	Unknown:            {"Unknown", "UNKNOWN"},
+	InvalidArgument:    {"InvalidArgument", "INVALID_ARGUMENT"},
+	DeadlineExceeded:   {"DeadlineExceeded", "DEADLINE_EXCEEDED"},
+	NotFound:           {"NotFound", "NOT_FOUND"},
+	AlreadyExists:      {"AlreadyExists", "ALREADY_EXISTS"},
+	PermissionDenied:   {"PermissionDenied", "PERMISSION_DENIED"},
+	ResourceExhausted:  {"ResourceExhausted", "RESOURCE_EXHAUSTED"},
+	FailedPrecondition: {"FailedPrecondition", "FAILED_PRECONDITION"},
+	Aborted:            {"Aborted", "ABORTED"},
+	OutOfRange:         {"OutOfRange", "OUT_OF_RANGE"},
+	Unimplemented:      {"Unimplemented", "UNIMPLEMENTED"},
+	Internal:           {"Internal", "INTERNAL"},
+	Unavailable:        {"Unavailable", "UNAVAILABLE"},
+	DataLoss:           {"DataLoss", "DATA_LOSS"},
+	Unauthenticated:    {"Unauthenticated", "UNAUTHENTICATED"},
+}
+
+// String returns the name of the StatusCode, such as "NotFound",
+// or "StatusCode(n)" for unknown codes.
+func (c StatusCode) String() string {
+	if c >= 0 && int(c) < len(statusCodeNames) {
+		return statusCodeNames[c].name
+	}
+
+	return "StatusCode(" + strconv.Itoa(int(c)) + ")"
+}
+
+// ParseStatusCode returns the StatusCode with the name, either the
+// name of the StatusCode, such as "NotFound", or its name in the gRPC
+// specification, such as "NOT_FOUND". Names are case insensitive.
+// A decimal number is parsed as the StatusCode with that value,
+// which must be one of the codes defined by gRPC, from 0 to 16.
+func ParseStatusCode(name string) (StatusCode, error) {
+	for c, names := range statusCodeNames {
+		if strings.EqualFold(name, names.name) || strings.EqualFold(name, names.specName) {
+			return StatusCode(c), nil
+		}
+	}
+
+	if n, err := strconv.ParseInt(name, 10, 32); err == nil && isValidCode(n) {
+		return StatusCode(n), nil
+	}
+
+	return 0, errors.New("grpcweb: invalid status code " + strconv.Quote(name))
+}
+
+// isValidCode reports whether n is one of the codes defined by gRPC.
+func isValidCode(n int64) bool {
+	return n >= 0 && n < int64(len(statusCodeNames))
+}
+
+// wireStatusCode returns the StatusCode of the code n received from
+// a server. Codes not defined by gRPC are mapped to Unknown.
+func wireStatusCode(n int64) StatusCode {
+	if !isValidCode(n) {
+		return Unknown
+	}
+
+	return StatusCode(n)
+}
+
+// MarshalText returns the name of the StatusCode in the gRPC
+// specification, such as "NOT_FOUND", or the decimal value
+// of unknown codes.
+func (c StatusCode) MarshalText() ([]byte, error) {
+	if c >= 0 && int(c) < len(statusCodeNames) {
+		return []byte(statusCodeNames[c].specName), nil
+	}
+
+	return []byte(strconv.Itoa(int(c))), nil
+}
+
+// UnmarshalText parses the StatusCode with ParseStatusCode.
+func (c *StatusCode) UnmarshalText(text []byte) error {
+	code, err := ParseStatusCode(string(text))
+	if err != nil {
+		return err
+	}
+
+	*c = code
+	return nil
+}
+
+// UnmarshalJSON parses a StatusCode from a JSON string,
+// using ParseStatusCode, or from a JSON number from 0 to 16.
+func (c *StatusCode) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		name, err := strconv.Unquote(string(b))
+		if err != nil {
+			return errors.New("grpcweb: invalid status code " + string(b))
+		}
+		return c.UnmarshalText([]byte(name))
+	}
+
+	n, err := strconv.ParseInt(string(b), 10, 32)
+	if err != nil || !isValidCode(n) {
+		return errors.New("grpcweb: invalid status code " + string(b))
+	}
+
+	*c = StatusCode(n)
+	return nil
 }
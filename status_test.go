@@ -0,0 +1,126 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseStatusCode(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		code  StatusCode
+		valid bool
+	}{
+		{"NotFound", NotFound, true},
+		{"NOT_FOUND", NotFound, true},
+		{"not_found", NotFound, true},
+		{"OK", Ok, true},
+		{"Ok", Ok, true},
+		{"0", Ok, true},
+		{"5", NotFound, true},
+		{"16", Unauthenticated, true},
+		{"17", 0, false},
+		{"-1", 0, false},
+		{"2147483648", 0, false},
+		{"", 0, false},
+		{"5.0", 0, false},
+		{"NoSuchCode", 0, false},
+	} {
+		code, err := ParseStatusCode(tt.name)
+		switch {
+		case tt.valid && err != nil:
+			t.Errorf("ParseStatusCode(%q) returned %v", tt.name, err)
+		case tt.valid && code != tt.code:
+			t.Errorf("ParseStatusCode(%q) = %v, want %v", tt.name, code, tt.code)
+		case !tt.valid && err == nil:
+			t.Errorf("ParseStatusCode(%q) = %v, want an error", tt.name, code)
+		}
+	}
+}
+
+func TestStatusCodeText(t *testing.T) {
+	for c := Ok; c <= Unauthenticated; c++ {
+		text, err := c.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got StatusCode
+		if err := got.UnmarshalText(text); err != nil {
+			t.Errorf("UnmarshalText(%q) returned %v", text, err)
+		}
+		if got != c {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", text, got, c)
+		}
+	}
+
+	for _, text := range []string{"17", "-1", "NoSuchCode", "NOT-FOUND"} {
+		c := NotFound
+		if err := c.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("UnmarshalText(%q) = %v, want an error", text, c)
+		}
+		if c != NotFound {
+			t.Errorf("UnmarshalText(%q) changed the code to %v", text, c)
+		}
+	}
+}
+
+func TestStatusCodeJSON(t *testing.T) {
+	for _, tt := range []struct {
+		json  string
+		code  StatusCode
+		valid bool
+	}{
+		{`"NOT_FOUND"`, NotFound, true},
+		{`"NotFound"`, NotFound, true},
+		{`"5"`, NotFound, true},
+		{`5`, NotFound, true},
+		{`0`, Ok, true},
+		{`16`, Unauthenticated, true},
+		{`17`, 0, false},
+		{`-1`, 0, false},
+		{`"17"`, 0, false},
+		{`99999999999`, 0, false},
+		{`5.5`, 0, false},
+		{`"NoSuchCode"`, 0, false},
+	} {
+		var code StatusCode
+		err := json.Unmarshal([]byte(tt.json), &code)
+		switch {
+		case tt.valid && err != nil:
+			t.Errorf("unmarshaling %s returned %v", tt.json, err)
+		case tt.valid && code != tt.code:
+			t.Errorf("unmarshaling %s = %v, want %v", tt.json, code, tt.code)
+		case !tt.valid && err == nil:
+			t.Errorf("unmarshaling %s = %v, want an error", tt.json, code)
+		}
+	}
+
+	b, err := json.Marshal(NotFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"NOT_FOUND"` {
+		t.Errorf("marshaling NotFound = %s, want %q", b, "NOT_FOUND")
+	}
+}
@@ -21,119 +21,267 @@
 package grpcweb
 
 import (
-	"time"
-
-	"github.com/gopherjs/gopherjs/js"
+	"sync"
 )
 
-// HTTPMethod is an enum for valid HTTP methods
-type HTTPMethod int
+// DefaultStreamBufferSize is the number of messages a server
+// stream buffers unless configured with WithStreamBuffer.
+const DefaultStreamBufferSize = 16
 
-func (h HTTPMethod) String() string {
-	return map[HTTPMethod]string{
-		GET:  "GET",
-		POST: "POST",
-	}[h]
-}
+// OverflowPolicy defines what happens when a message arrives
+// on a server stream whose buffer is full.
+type OverflowPolicy int
 
-// Define HTTP methods
 const (
-	GET = HTTPMethod(iota)
-	POST
-	// Don't really need any other methods
+	// BlockOnOverflow makes the producer wait until the consumer
+	// has made room in the buffer. No messages are lost.
+	BlockOnOverflow = OverflowPolicy(iota)
+
+	// DropOldestOnOverflow discards the oldest buffered message
+	// to make room for the new one.
+	DropOldestOnOverflow
+
+	// FailOnOverflow aborts the stream. Any buffered messages are
+	// still delivered, followed by a ResourceExhausted error.
+	FailOnOverflow
 )
 
-// XHRIO encapsulates the google XhrIO class.
-type XHRIO struct {
-	*js.Object
+// ClientStream is a stream of response messages from a server.
+type ClientStream interface {
+	// Recv returns the next message of the stream. Once the stream
+	// has finished, it returns EOF, or the error that terminated it.
+	Recv() ([]byte, error)
+	// Close aborts the stream.
+	Close()
 }
 
-// SetRequestHeader sets the header key to value
-func (x *XHRIO) SetRequestHeader(key, value string) {
-	x.Get("headers").Call("set", key, value)
+// StreamWriter sends the messages of a client streaming call.
+type StreamWriter interface {
+	// Send sends a message to the server.
+	Send(req ProtoMessage) error
+	// CloseAndRecv closes the sending side of the stream
+	// and waits for the response from the server.
+	CloseAndRecv() ([]byte, error)
 }
 
-// SetTimeout sets the header key to value
-func (x *XHRIO) SetTimeout(timeout time.Duration) {
-	x.Call("setTimeoutInterval", int(timeout.Seconds()*1000))
+// BidiStream sends and receives the messages
+// of a bidirectional streaming call.
+type BidiStream interface {
+	ClientStream
+	// Send sends a message to the server.
+	Send(req ProtoMessage) error
+	// CloseSend closes the sending side of the stream.
+	CloseSend() error
 }
 
-// Send sends the data to the endpoint using the method
-func (x *XHRIO) Send(endpoint string, method HTTPMethod, data []byte) {
-	x.Call("send", endpoint, method.String(), js.Global.Get("Uint8Array").New(data))
-}
+// bidiStream implements BidiStream on top of a DuplexTransportStream.
+type bidiStream struct {
+	*StreamReader
+	ts    DuplexTransportStream
+	ci    *callInfo
+	stats *callStats
 
-// Abort closes the XHR stream
-func (x *XHRIO) Abort() {
-	x.Call("abort")
+	// refresh refreshes the credentials of the client
+	// once the call has failed with Unauthenticated.
+	refresh   func(creds Metadata, err error) bool
+	refreshed sync.Once
 }
 
-// NewXHRIO initializes an XHRIO object.
-func NewXHRIO() *XHRIO {
-	return &XHRIO{
-		Object: js.Global.Get("goog").Get("net").Get("XhrIo").New(),
+func (b *bidiStream) Recv() ([]byte, error) {
+	msg, err := b.StreamReader.Recv()
+	if err != nil && err != EOF {
+		b.refreshed.Do(func() {
+			b.refresh(b.creds, err)
+		})
 	}
+
+	return msg, err
 }
 
-// EventType is a NodeReadableStream event type
-// Defined in
-// https://github.com/google/closure-library/blob/master/closure/goog/net/streams/nodereadablestream.js#L54
-type EventType string
+func (b *bidiStream) Send(req ProtoMessage) error {
+	msg, err := req.Serialize()
+	if err != nil {
+		return err
+	}
+	if err := b.ci.checkSendSize(msg); err != nil {
+		return err
+	}
+	if err := b.ts.Send(msg); err != nil {
+		return err
+	}
+	b.stats.outPayload(len(msg), len(msg))
 
-// All the defined EventTypes
-const (
-	READABLE = EventType("readable")
-	DATA     = EventType("data")
-	END      = EventType("end")
-	CLOSE    = EventType("close")
-	ERROR    = EventType("error")
-)
+	return nil
+}
 
-// XHRNodeReadableStream encapsulates a google
-// XhrNodeReadableStream class
-type XHRNodeReadableStream struct {
-	*js.Object
-	xhr *XHRIO
+func (b *bidiStream) CloseSend() error {
+	return b.ts.CloseSend()
 }
 
-// NewXHRNodeReadableStream initializes an
-// XHRNodeReadableStream object with the provided XhrIO.
-func NewXHRNodeReadableStream(xhrIO *XHRIO) *XHRNodeReadableStream {
-	xhrnrs := &XHRNodeReadableStream{
-		Object: js.Global.Get("goog").Get("net").Get("streams").Call("createXhrNodeReadableStream", xhrIO.Object),
-	}
-	xhrnrs.xhr = xhrIO
+// interceptedStream is the BidiStream of a call whose ClientStream
+// was wrapped by stream interceptors. Messages are received from
+// the wrapper and sent on the stream of the call.
+type interceptedStream struct {
+	ClientStream
+	sender *bidiStream
+}
+
+func (s *interceptedStream) Send(req ProtoMessage) error {
+	return s.sender.Send(req)
+}
 
-	return xhrnrs
+func (s *interceptedStream) CloseSend() error {
+	return s.sender.CloseSend()
 }
 
-// On sets the callback handler for the given event
-func (x *XHRNodeReadableStream) On(event EventType, callback func(*js.Object)) {
-	x.Call("on", string(event), callback)
+// streamWriter implements StreamWriter on top of a BidiStream.
+type streamWriter struct {
+	BidiStream
 }
 
-// Abort closes the stream
-func (x *XHRNodeReadableStream) Abort() {
-	x.xhr.Abort()
+func (s *streamWriter) CloseAndRecv() ([]byte, error) {
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	return recvUnary(s)
 }
 
+// StreamReader reads the messages of a server stream. Messages are
+// returned in the order they were received, and the terminal
+// error is only returned once all buffered messages have been read.
 type StreamReader struct {
-	respChan <-chan []byte
-	errChan  <-chan error
+	mu     sync.Mutex
+	msgs   [][]byte
+	size   int
+	policy OverflowPolicy
+	done   bool
+	err    error
+	abort  func()
+
+	// trailer is set before the stream is finished
+	// with the status received from the server.
+	trailer Metadata
+	// creds is the metadata added to the request
+	// by the credentials of the client.
+	creds Metadata
+
+	// ready and space are used to wake up a blocked
+	// consumer and producer respectively.
+	ready chan struct{}
+	space chan struct{}
 }
 
-func NewStreamReader(respChan <-chan []byte, errChan <-chan error) *StreamReader {
+func newStreamReader(size int, policy OverflowPolicy, abort func()) *StreamReader {
 	return &StreamReader{
-		respChan: respChan,
-		errChan:  errChan,
+		size:   size,
+		policy: policy,
+		abort:  abort,
+		ready:  make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
 	}
 }
 
+// Recv returns the next message of the stream. Once the stream has
+// finished, it returns EOF, or the error that terminated the stream.
 func (s *StreamReader) Recv() ([]byte, error) {
+	for {
+		s.mu.Lock()
+		if len(s.msgs) > 0 {
+			msg := s.msgs[0]
+			s.msgs[0] = nil
+			s.msgs = s.msgs[1:]
+			s.mu.Unlock()
+			wake(s.space)
+			return msg, nil
+		}
+		if s.done {
+			err := s.err
+			s.mu.Unlock()
+			return nil, err
+		}
+		s.mu.Unlock()
+
+		<-s.ready
+	}
+}
+
+// Close aborts the stream. Any buffered messages are discarded
+// and subsequent calls to Recv return a Cancelled error.
+func (s *StreamReader) Close() {
+	s.mu.Lock()
+	s.msgs = nil
+	s.mu.Unlock()
+
+	s.finish(&Error{Code: Cancelled, Message: "stream closed"})
+	s.abort()
+}
+
+// push adds a message to the buffer, applying the overflow policy
+// if it is full. It may block, so it must not be called directly
+// from a JS callback. It returns false once the stream is finished.
+func (s *StreamReader) push(msg []byte) bool {
+	for {
+		s.mu.Lock()
+		if s.done {
+			s.mu.Unlock()
+			return false
+		}
+		if len(s.msgs) < s.size {
+			s.msgs = append(s.msgs, msg)
+			s.mu.Unlock()
+			wake(s.ready)
+			return true
+		}
+
+		switch s.policy {
+		case DropOldestOnOverflow:
+			s.msgs[0] = nil
+			s.msgs = append(s.msgs[1:], msg)
+			s.mu.Unlock()
+			wake(s.ready)
+			return true
+		case FailOnOverflow:
+			s.done = true
+			s.err = &Error{Code: ResourceExhausted, Message: "stream buffer full"}
+			s.mu.Unlock()
+			wake(s.ready)
+			s.abort()
+			return false
+		}
+		s.mu.Unlock()
+
+		// BlockOnOverflow: wait for the consumer to read a message
+		<-s.space
+	}
+}
+
+// result returns the error the stream was finished with,
+// or nil if it hasn't finished.
+func (s *StreamReader) result() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// finish terminates the stream with err. Only the first call has
+// any effect; subsequent errors are ignored.
+func (s *StreamReader) finish(err error) {
+	s.mu.Lock()
+	if !s.done {
+		s.done = true
+		s.err = err
+	}
+	s.mu.Unlock()
+	wake(s.ready)
+	wake(s.space)
+}
+
+// wake performs a non-blocking send on c
+func wake(c chan struct{}) {
 	select {
-	case resp := <-s.respChan:
-		return resp, nil
-	case err := <-s.errChan:
-		return nil, err
+	case c <- struct{}{}:
+	default:
 	}
 }
@@ -0,0 +1,200 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"testing"
+	"time"
+)
+
+// abortCounter counts the calls to the abort function of a StreamReader
+type abortCounter int
+
+func (a *abortCounter) abort() {
+	*a++
+}
+
+// recvAll receives n messages from s
+func recvAll(t *testing.T, s *StreamReader, n int) []string {
+	t.Helper()
+
+	var msgs []string
+	for i := 0; i < n; i++ {
+		msg, err := s.Recv()
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		msgs = append(msgs, string(msg))
+	}
+
+	return msgs
+}
+
+func checkMessages(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got messages %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got messages %q, want %q", got, want)
+		}
+	}
+}
+
+func TestStreamReaderOrder(t *testing.T) {
+	var aborts abortCounter
+	s := newStreamReader(3, BlockOnOverflow, aborts.abort)
+	for _, msg := range []string{"one", "two", "three"} {
+		if !s.push([]byte(msg)) {
+			t.Fatalf("push(%q) = false", msg)
+		}
+	}
+	// The terminal error follows the buffered messages
+	s.finish(EOF)
+	s.finish(&Error{Code: Internal})
+
+	checkMessages(t, recvAll(t, s, 3), "one", "two", "three")
+	for i := 0; i < 2; i++ {
+		if _, err := s.Recv(); err != EOF {
+			t.Fatalf("got %v after the messages, want EOF", err)
+		}
+	}
+	if s.push([]byte("four")) {
+		t.Error("push succeeded after the stream finished")
+	}
+	if aborts != 0 {
+		t.Errorf("the stream was aborted %d times, want 0", aborts)
+	}
+}
+
+func TestStreamReaderBlockOnOverflow(t *testing.T) {
+	var aborts abortCounter
+	s := newStreamReader(2, BlockOnOverflow, aborts.abort)
+	s.push([]byte("one"))
+	s.push([]byte("two"))
+
+	pushed := make(chan bool)
+	go func() {
+		pushed <- s.push([]byte("three"))
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push did not block on a full buffer")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.mu.Lock()
+	if len(s.msgs) != 2 {
+		t.Errorf("the buffer holds %d messages, want 2", len(s.msgs))
+	}
+	s.mu.Unlock()
+
+	checkMessages(t, recvAll(t, s, 1), "one")
+	if !<-pushed {
+		t.Fatal("blocked push failed")
+	}
+	s.finish(EOF)
+	checkMessages(t, recvAll(t, s, 2), "two", "three")
+	if aborts != 0 {
+		t.Errorf("the stream was aborted %d times, want 0", aborts)
+	}
+}
+
+func TestStreamReaderDropOldestOnOverflow(t *testing.T) {
+	var aborts abortCounter
+	s := newStreamReader(2, DropOldestOnOverflow, aborts.abort)
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		if !s.push([]byte(msg)) {
+			t.Fatalf("push(%q) = false", msg)
+		}
+	}
+	s.mu.Lock()
+	if len(s.msgs) != 2 {
+		t.Errorf("the buffer holds %d messages, want 2", len(s.msgs))
+	}
+	s.mu.Unlock()
+	s.finish(EOF)
+
+	checkMessages(t, recvAll(t, s, 2), "three", "four")
+	if _, err := s.Recv(); err != EOF {
+		t.Errorf("got %v after the messages, want EOF", err)
+	}
+	if aborts != 0 {
+		t.Errorf("the stream was aborted %d times, want 0", aborts)
+	}
+}
+
+func TestStreamReaderFailOnOverflow(t *testing.T) {
+	var aborts abortCounter
+	s := newStreamReader(2, FailOnOverflow, aborts.abort)
+	s.push([]byte("one"))
+	s.push([]byte("two"))
+	if s.push([]byte("three")) {
+		t.Fatal("push succeeded on a full buffer")
+	}
+	if aborts != 1 {
+		t.Errorf("the stream was aborted %d times, want 1", aborts)
+	}
+	// Later errors don't replace ResourceExhausted
+	s.finish(EOF)
+	if s.push([]byte("four")) {
+		t.Error("push succeeded after the stream failed")
+	}
+
+	// The buffered messages are still delivered
+	checkMessages(t, recvAll(t, s, 2), "one", "two")
+	_, err := s.Recv()
+	if e, ok := err.(*Error); !ok || e.Code != ResourceExhausted {
+		t.Errorf("got %v after the messages, want a ResourceExhausted error", err)
+	}
+}
+
+func TestStreamReaderClose(t *testing.T) {
+	var aborts abortCounter
+	s := newStreamReader(1, BlockOnOverflow, aborts.abort)
+	s.push([]byte("one"))
+
+	// Close wakes up a blocked producer
+	pushed := make(chan bool)
+	go func() {
+		pushed <- s.push([]byte("two"))
+	}()
+	s.Close()
+	if <-pushed {
+		t.Error("blocked push succeeded after Close")
+	}
+	if aborts != 1 {
+		t.Errorf("the stream was aborted %d times, want 1", aborts)
+	}
+
+	// Buffered messages are discarded
+	for i := 0; i < 2; i++ {
+		_, err := s.Recv()
+		if e, ok := err.(*Error); !ok || e.Code != Cancelled {
+			t.Fatalf("got %v after Close, want a Cancelled error", err)
+		}
+	}
+	s.finish(EOF)
+	if _, err := s.Recv(); err == EOF {
+		t.Error("finish replaced the Cancelled error")
+	}
+}
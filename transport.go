@@ -0,0 +1,167 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpcweb
+
+import (
+	"sync"
+	"time"
+)
+
+// Transport sends requests to a server and receives the responses.
+// The protocol used to encode requests and decode responses is
+// defined by the Transport.
+type Transport interface {
+	// NewStream sends the request and returns a
+	// TransportStream for reading the response.
+	NewStream(req *TransportRequest) (TransportStream, error)
+}
+
+// StreamingTransport is a Transport that can also stream requests,
+// as required by client streaming and bidirectional streaming calls.
+type StreamingTransport interface {
+	Transport
+	// NewDuplexStream opens a stream to the endpoint of the request.
+	// The Body of the request is ignored; messages are sent
+	// with the Send method of the returned stream instead.
+	NewDuplexStream(req *TransportRequest) (DuplexTransportStream, error)
+}
+
+// DuplexTransportStream is a TransportStream that can also send messages.
+type DuplexTransportStream interface {
+	TransportStream
+	// Send sends a serialized message to the server.
+	Send(msg []byte) error
+	// CloseSend tells the server no more messages will be sent.
+	CloseSend() error
+}
+
+// TransportRequest is a request sent by a Transport.
+type TransportRequest struct {
+	// Endpoint is the URL the request is sent to.
+	Endpoint string
+	// Header holds the request headers.
+	Header Metadata
+	// Body is the serialized request message.
+	Body []byte
+	// Timeout aborts the request if no status has been
+	// received in time. A value of 0 means no timeout.
+	Timeout time.Duration
+	// Encoding is the encoding of the request body. Transports
+	// for protocols with a fixed encoding ignore it.
+	Encoding Encoding
+	// Compressor, if set, compresses the request messages. Transports
+	// for protocols without message compression ignore it.
+	Compressor Compressor
+	// MaxRecvMsgSize is the largest response message accepted,
+	// in bytes. Larger messages fail the response with
+	// ResourceExhausted before they are buffered.
+	// A value of 0 or less means no limit.
+	MaxRecvMsgSize int
+}
+
+// TransportStream is the response to a request sent by a Transport.
+type TransportStream interface {
+	// Header returns the response headers. It is only valid
+	// once Recv has returned the first frame or an error.
+	Header() Metadata
+	// Recv returns the next frame of the response. The last frame
+	// holds the status of the response. If the response fails before
+	// a status is received, Recv returns an *Error describing the failure.
+	Recv() (*Frame, error)
+	// Close aborts the request.
+	Close()
+}
+
+// Frame is a single part of a response received by a Transport.
+// Exactly one of Message and Status is set.
+type Frame struct {
+	// Message is a serialized response message.
+	Message []byte
+	// Compressed reports whether Message is compressed with
+	// the Compressor named in the grpc-encoding header.
+	Compressed bool
+	// Status is the status of the response.
+	// It is only set on the final frame.
+	Status *Status
+}
+
+// frameQueue is an unbounded queue of frames, used by transports
+// that receive frames in JS callbacks, which must never block.
+type frameQueue struct {
+	mu     sync.Mutex
+	frames []*Frame
+	done   bool
+	err    error
+	ready  chan struct{}
+}
+
+func newFrameQueue() *frameQueue {
+	return &frameQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// put adds a frame to the queue. Frames put after the
+// queue has been failed or has received a status are ignored.
+func (q *frameQueue) put(f *Frame) {
+	q.mu.Lock()
+	if !q.done {
+		q.frames = append(q.frames, f)
+		q.done = f.Status != nil
+	}
+	q.mu.Unlock()
+	wake(q.ready)
+}
+
+// fail ends the queue with err. It has no effect
+// if the queue has already ended.
+func (q *frameQueue) fail(err error) {
+	q.mu.Lock()
+	if !q.done {
+		q.done = true
+		q.err = err
+	}
+	q.mu.Unlock()
+	wake(q.ready)
+}
+
+// get returns the next frame in the queue, blocking until
+// one is available, or the error the queue was failed with.
+func (q *frameQueue) get() (*Frame, error) {
+	for {
+		q.mu.Lock()
+		if len(q.frames) > 0 {
+			f := q.frames[0]
+			q.frames[0] = nil
+			q.frames = q.frames[1:]
+			q.mu.Unlock()
+			return f, nil
+		}
+		if q.done {
+			err := q.err
+			q.mu.Unlock()
+			return nil, err
+		}
+		q.mu.Unlock()
+
+		<-q.ready
+	}
+}
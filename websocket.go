@@ -0,0 +1,204 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build js && !wasm
+// +build js,!wasm
+
+package grpcweb
+
+import (
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// websocketProtocol is the subprotocol used by
+// the improbable-eng grpcweb websocket wrapper.
+const websocketProtocol = "grpc-websockets"
+
+// Control bytes prefixed to messages sent by the client
+const (
+	wsMessageFlag    = 0
+	wsFinishSendFlag = 1
+)
+
+// websocketTransport is a StreamingTransport using the WebSocket API,
+// compatible with the websocket wrapper of the improbable-eng grpcweb
+// Go package.
+type websocketTransport struct{}
+
+// NewWebsocketTransport returns a StreamingTransport that makes
+// calls over a WebSocket, using the framing of the improbable-eng
+// grpcweb websocket wrapper. Endpoints using http and https are
+// translated to ws and wss respectively.
+func NewWebsocketTransport() StreamingTransport {
+	return websocketTransport{}
+}
+
+// NewStream implements Transport.
+func (t websocketTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	s, err := t.NewDuplexStream(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.Send(req.Body); err == nil {
+		err = s.CloseSend()
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewDuplexStream implements StreamingTransport.
+func (websocketTransport) NewDuplexStream(req *TransportRequest) (DuplexTransportStream, error) {
+	ws := js.Global.Get("WebSocket").New(websocketURL(req.Endpoint), []string{websocketProtocol})
+	ws.Set("binaryType", "arraybuffer")
+
+	s := &websocketStream{
+		ws:         ws,
+		frames:     newFrameQueue(),
+		decoder:    frameDecoder{headerFrame: true, maxMsgSize: req.MaxRecvMsgSize},
+		compressor: req.Compressor,
+		opened:     make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+
+	ws.Set("onopen", func(_ *js.Object) {
+		// The request headers are sent as the first message
+		header := "content-type: " + grpcWebContentType + "\r\nx-grpc-web: 1\r\n"
+		headerValues(req.Header, func(k, v string) {
+			header += k + ": " + v + "\r\n"
+		})
+		compressionHeaders(req.Compressor, func(k, v string) {
+			header += k + ": " + v + "\r\n"
+		})
+		if req.Timeout > 0 {
+			header += "grpc-timeout: " + encodeTimeout(req.Timeout) + "\r\n"
+		}
+		ws.Call("send", js.Global.Get("Uint8Array").New([]byte(header)))
+		close(s.opened)
+	})
+	ws.Set("onmessage", func(e *js.Object) {
+		frames, err := s.decoder.decode(js.Global.Get("Uint8Array").New(e.Get("data")).Interface().([]byte))
+		for _, f := range frames {
+			s.frames.put(f)
+		}
+		if err != nil {
+			s.frames.fail(err)
+			s.Close()
+		}
+	})
+	ws.Set("onerror", func(_ *js.Object) {
+		s.frames.fail(&Error{Code: Unavailable, Message: "websocket error"})
+	})
+	ws.Set("onclose", func(e *js.Object) {
+		s.closeOnce.Do(func() { close(s.closed) })
+		if s.timer != nil {
+			js.Global.Call("clearTimeout", s.timer)
+		}
+		// Only has an effect if no status was received
+		s.frames.fail(&Error{Code: Unavailable, Message: "websocket closed: " + e.Get("reason").String()})
+	})
+	if req.Timeout > 0 {
+		// The timeout covers the whole stream, including the
+		// time spent waiting for the client to send messages.
+		s.timer = js.Global.Call("setTimeout", func() {
+			s.frames.fail(&Error{Code: DeadlineExceeded, Message: "request timed out"})
+			ws.Call("close")
+		}, timerMillis(req.Timeout))
+	}
+
+	return s, nil
+}
+
+// websocketStream is the DuplexTransportStream of a websocketTransport.
+type websocketStream struct {
+	ws         *js.Object
+	frames     *frameQueue
+	decoder    frameDecoder
+	compressor Compressor
+	timer      *js.Object
+
+	opened    chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *websocketStream) Header() Metadata {
+	return s.decoder.header
+}
+
+func (s *websocketStream) Recv() (*Frame, error) {
+	return s.frames.get()
+}
+
+// Send waits for the WebSocket to be opened before sending.
+func (s *websocketStream) Send(msg []byte) error {
+	frame, err := encodeFrame(msg, s.compressor)
+	if err != nil {
+		return err
+	}
+
+	return s.send(append([]byte{wsMessageFlag}, frame...))
+}
+
+func (s *websocketStream) CloseSend() error {
+	return s.send([]byte{wsFinishSendFlag})
+}
+
+func (s *websocketStream) send(data []byte) error {
+	select {
+	case <-s.opened:
+	case <-s.closed:
+		return &Error{Code: Unavailable, Message: "websocket closed"}
+	}
+
+	s.ws.Call("send", js.Global.Get("Uint8Array").New(data))
+	return nil
+}
+
+func (s *websocketStream) Close() {
+	s.frames.fail(&Error{Code: Cancelled, Message: "stream cancelled"})
+	s.ws.Call("close")
+}
+
+// websocketURL resolves the endpoint against the location of the
+// page, and translates http and https URLs to ws and wss.
+func websocketURL(endpoint string) string {
+	var u *js.Object
+	if location := js.Global.Get("location"); location != js.Undefined {
+		u = js.Global.Get("URL").New(endpoint, location.Get("href"))
+	} else {
+		u = js.Global.Get("URL").New(endpoint)
+	}
+
+	switch u.Get("protocol").String() {
+	case "http:":
+		u.Set("protocol", "ws:")
+	case "https:":
+		u.Set("protocol", "wss:")
+	}
+
+	return u.Call("toString").String()
+}
@@ -0,0 +1,320 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package wire implements encoding and decoding of the protobuf
+// wire format. It is used by the code generated by protoc-gen-gopherjs.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Type is a protobuf wire type.
+type Type int
+
+// All the wire types
+const (
+	Varint     = Type(0)
+	Fixed64    = Type(1)
+	Bytes      = Type(2)
+	StartGroup = Type(3)
+	EndGroup   = Type(4)
+	Fixed32    = Type(5)
+)
+
+// Errors returned when decoding
+var (
+	ErrTruncated = errors.New("wire: message is truncated")
+	ErrOverflow  = errors.New("wire: varint overflows 64 bits")
+	ErrWireType  = errors.New("wire: invalid wire type")
+	// ErrUnexpectedWireType is returned by generated code for
+	// a field whose wire type doesn't match its declared type.
+	ErrUnexpectedWireType = errors.New("wire: unexpected wire type for field")
+)
+
+// Message is implemented by all generated messages.
+type Message interface {
+	Serialize() ([]byte, error)
+	Deserialize([]byte) error
+}
+
+// Buffer encodes or decodes messages in the protobuf wire format.
+type Buffer struct {
+	buf []byte
+	idx int
+}
+
+// NewBuffer returns a Buffer for decoding b, or for
+// encoding a message appended to b.
+func NewBuffer(b []byte) *Buffer {
+	return &Buffer{buf: b}
+}
+
+// Bytes returns the encoded bytes.
+func (b *Buffer) Bytes() []byte {
+	return b.buf
+}
+
+// Done reports whether all the bytes have been decoded.
+func (b *Buffer) Done() bool {
+	return b.idx >= len(b.buf)
+}
+
+// EncodeTag encodes the tag of the field with the number field.
+func (b *Buffer) EncodeTag(field int32, typ Type) {
+	b.EncodeVarint(uint64(field)<<3 | uint64(typ))
+}
+
+// EncodeVarint encodes v as a varint.
+func (b *Buffer) EncodeVarint(v uint64) {
+	for v >= 0x80 {
+		b.buf = append(b.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	b.buf = append(b.buf, byte(v))
+}
+
+// EncodeZigzag encodes v as a zigzag encoded varint,
+// as used by sint32 and sint64 fields.
+func (b *Buffer) EncodeZigzag(v int64) {
+	b.EncodeVarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// EncodeFixed32 encodes v as 4 little endian bytes.
+func (b *Buffer) EncodeFixed32(v uint32) {
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], v)
+}
+
+// EncodeFixed64 encodes v as 8 little endian bytes.
+func (b *Buffer) EncodeFixed64(v uint64) {
+	b.buf = append(b.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(b.buf[len(b.buf)-8:], v)
+}
+
+// EncodeFloat encodes v as a float field.
+func (b *Buffer) EncodeFloat(v float32) {
+	b.EncodeFixed32(math.Float32bits(v))
+}
+
+// EncodeDouble encodes v as a double field.
+func (b *Buffer) EncodeDouble(v float64) {
+	b.EncodeFixed64(math.Float64bits(v))
+}
+
+// EncodeBool encodes v as a bool field.
+func (b *Buffer) EncodeBool(v bool) {
+	if v {
+		b.EncodeVarint(1)
+	} else {
+		b.EncodeVarint(0)
+	}
+}
+
+// EncodeBytes encodes v with a length prefix.
+func (b *Buffer) EncodeBytes(v []byte) {
+	b.EncodeVarint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+// EncodeString encodes v with a length prefix.
+func (b *Buffer) EncodeString(v string) {
+	b.EncodeVarint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+// EncodeMessage encodes m with a length prefix.
+func (b *Buffer) EncodeMessage(m Message) error {
+	v, err := m.Serialize()
+	if err != nil {
+		return err
+	}
+
+	b.EncodeBytes(v)
+	return nil
+}
+
+// DecodeTag decodes the tag of the next field.
+func (b *Buffer) DecodeTag() (field int32, typ Type, err error) {
+	v, err := b.DecodeVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	typ = Type(v & 7)
+	if typ > Fixed32 {
+		return 0, 0, ErrWireType
+	}
+
+	return int32(v >> 3), typ, nil
+}
+
+// DecodeVarint decodes a varint.
+func (b *Buffer) DecodeVarint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if b.idx >= len(b.buf) {
+			return 0, ErrTruncated
+		}
+		c := b.buf[b.idx]
+		b.idx++
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, nil
+		}
+	}
+
+	return 0, ErrOverflow
+}
+
+// DecodeZigzag decodes a zigzag encoded varint.
+func (b *Buffer) DecodeZigzag() (int64, error) {
+	v, err := b.DecodeVarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// DecodeFixed32 decodes 4 little endian bytes.
+func (b *Buffer) DecodeFixed32() (uint32, error) {
+	if len(b.buf)-b.idx < 4 {
+		return 0, ErrTruncated
+	}
+	v := binary.LittleEndian.Uint32(b.buf[b.idx:])
+	b.idx += 4
+
+	return v, nil
+}
+
+// DecodeFixed64 decodes 8 little endian bytes.
+func (b *Buffer) DecodeFixed64() (uint64, error) {
+	if len(b.buf)-b.idx < 8 {
+		return 0, ErrTruncated
+	}
+	v := binary.LittleEndian.Uint64(b.buf[b.idx:])
+	b.idx += 8
+
+	return v, nil
+}
+
+// DecodeFloat decodes a float field.
+func (b *Buffer) DecodeFloat() (float32, error) {
+	v, err := b.DecodeFixed32()
+	return math.Float32frombits(v), err
+}
+
+// DecodeDouble decodes a double field.
+func (b *Buffer) DecodeDouble() (float64, error) {
+	v, err := b.DecodeFixed64()
+	return math.Float64frombits(v), err
+}
+
+// DecodeBool decodes a bool field.
+func (b *Buffer) DecodeBool() (bool, error) {
+	v, err := b.DecodeVarint()
+	return v != 0, err
+}
+
+// DecodeBytes decodes a length prefixed value.
+// The returned slice is a copy of the decoded bytes.
+func (b *Buffer) DecodeBytes() ([]byte, error) {
+	n, err := b.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(b.buf)-b.idx) {
+		return nil, ErrTruncated
+	}
+
+	v := make([]byte, n)
+	copy(v, b.buf[b.idx:])
+	b.idx += int(n)
+
+	return v, nil
+}
+
+// DecodeString decodes a length prefixed string.
+func (b *Buffer) DecodeString() (string, error) {
+	v, err := b.DecodeBytes()
+	return string(v), err
+}
+
+// DecodeMessage decodes a length prefixed message into m.
+func (b *Buffer) DecodeMessage(m Message) error {
+	v, err := b.DecodeBytes()
+	if err != nil {
+		return err
+	}
+
+	return m.Deserialize(v)
+}
+
+// DecodePacked calls decode for each value of a packed repeated
+// field, with a Buffer positioned at the value.
+func (b *Buffer) DecodePacked(decode func(*Buffer) error) error {
+	v, err := b.DecodeBytes()
+	if err != nil {
+		return err
+	}
+
+	packed := NewBuffer(v)
+	for !packed.Done() {
+		if err := decode(packed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Skip skips a field of the provided wire type.
+func (b *Buffer) Skip(field int32, typ Type) error {
+	var err error
+	switch typ {
+	case Varint:
+		_, err = b.DecodeVarint()
+	case Fixed64:
+		_, err = b.DecodeFixed64()
+	case Fixed32:
+		_, err = b.DecodeFixed32()
+	case Bytes:
+		_, err = b.DecodeBytes()
+	case StartGroup:
+		for {
+			f, t, err := b.DecodeTag()
+			if err != nil {
+				return err
+			}
+			if t == EndGroup {
+				if f != field {
+					return ErrWireType
+				}
+				return nil
+			}
+			if err := b.Skip(f, t); err != nil {
+				return err
+			}
+		}
+	default:
+		err = ErrWireType
+	}
+
+	return err
+}
@@ -0,0 +1,336 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package wire
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	for _, tt := range []struct {
+		v   uint64
+		enc []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{300, []byte{0xac, 0x02}},
+		{math.MaxUint32, []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{math.MaxUint64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	} {
+		b := NewBuffer(nil)
+		b.EncodeVarint(tt.v)
+		if !bytes.Equal(b.Bytes(), tt.enc) {
+			t.Errorf("EncodeVarint(%d) = %x, want %x", tt.v, b.Bytes(), tt.enc)
+		}
+
+		b = NewBuffer(tt.enc)
+		v, err := b.DecodeVarint()
+		if err != nil || v != tt.v || !b.Done() {
+			t.Errorf("DecodeVarint(%x) = %d, %v, want %d", tt.enc, v, err, tt.v)
+		}
+	}
+}
+
+func TestDecodeVarintErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		enc  []byte
+		err  error
+	}{
+		{"empty", nil, ErrTruncated},
+		{"truncated", []byte{0x80}, ErrTruncated},
+		{"truncated long", []byte{0xff, 0xff, 0xff, 0xff}, ErrTruncated},
+		{"overflow", bytes.Repeat([]byte{0x80}, 11), ErrOverflow},
+	} {
+		if _, err := NewBuffer(tt.enc).DecodeVarint(); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	for _, tt := range []struct {
+		v   int64
+		enc uint64
+	}{
+		{0, 0},
+		{-1, 1},
+		{1, 2},
+		{-2, 3},
+		{math.MaxInt32, math.MaxUint32 - 1},
+		{math.MinInt32, math.MaxUint32},
+		{math.MaxInt64, math.MaxUint64 - 1},
+		{math.MinInt64, math.MaxUint64},
+	} {
+		b := NewBuffer(nil)
+		b.EncodeZigzag(tt.v)
+		enc, err := NewBuffer(b.Bytes()).DecodeVarint()
+		if err != nil || enc != tt.enc {
+			t.Errorf("EncodeZigzag(%d) encoded %d, %v, want %d", tt.v, enc, err, tt.enc)
+		}
+
+		v, err := NewBuffer(b.Bytes()).DecodeZigzag()
+		if err != nil || v != tt.v {
+			t.Errorf("DecodeZigzag(%d) = %d, %v, want %d", tt.enc, v, err, tt.v)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	b := NewBuffer(nil)
+	b.EncodeFixed32(0x01020304)
+	b.EncodeFixed64(0x0102030405060708)
+	b.EncodeFloat(-1.5)
+	b.EncodeDouble(math.Inf(1))
+	want := []byte{
+		0x04, 0x03, 0x02, 0x01,
+		0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01,
+		0x00, 0x00, 0xc0, 0xbf,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x7f,
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Fatalf("got %x, want %x", b.Bytes(), want)
+	}
+
+	b = NewBuffer(want)
+	if v, err := b.DecodeFixed32(); err != nil || v != 0x01020304 {
+		t.Errorf("DecodeFixed32 = %x, %v", v, err)
+	}
+	if v, err := b.DecodeFixed64(); err != nil || v != 0x0102030405060708 {
+		t.Errorf("DecodeFixed64 = %x, %v", v, err)
+	}
+	if v, err := b.DecodeFloat(); err != nil || v != -1.5 {
+		t.Errorf("DecodeFloat = %v, %v", v, err)
+	}
+	if v, err := b.DecodeDouble(); err != nil || !math.IsInf(v, 1) {
+		t.Errorf("DecodeDouble = %v, %v", v, err)
+	}
+	if !b.Done() {
+		t.Error("bytes left after decoding")
+	}
+
+	if _, err := NewBuffer([]byte{1, 2, 3}).DecodeFixed32(); err != ErrTruncated {
+		t.Errorf("DecodeFixed32 of 3 bytes returned %v, want ErrTruncated", err)
+	}
+	if _, err := NewBuffer([]byte{1, 2, 3, 4, 5, 6, 7}).DecodeFixed64(); err != ErrTruncated {
+		t.Errorf("DecodeFixed64 of 7 bytes returned %v, want ErrTruncated", err)
+	}
+}
+
+func TestBool(t *testing.T) {
+	b := NewBuffer(nil)
+	b.EncodeBool(true)
+	b.EncodeBool(false)
+	if !bytes.Equal(b.Bytes(), []byte{1, 0}) {
+		t.Fatalf("got %x, want 0100", b.Bytes())
+	}
+
+	// Any non-zero varint is true
+	b = NewBuffer([]byte{0x01, 0x00, 0x80, 0x01})
+	for _, want := range []bool{true, false, true} {
+		if v, err := b.DecodeBool(); err != nil || v != want {
+			t.Errorf("DecodeBool = %t, %v, want %t", v, err, want)
+		}
+	}
+}
+
+func TestLengthDelimited(t *testing.T) {
+	long := string(bytes.Repeat([]byte{'a'}, 200))
+	for _, v := range []string{"", "hello", long} {
+		b := NewBuffer(nil)
+		b.EncodeString(v)
+		b.EncodeBytes([]byte(v))
+
+		d := NewBuffer(b.Bytes())
+		s, err := d.DecodeString()
+		if err != nil || s != v {
+			t.Errorf("DecodeString = %q, %v, want %q", s, err, v)
+		}
+		p, err := d.DecodeBytes()
+		if err != nil || string(p) != v {
+			t.Errorf("DecodeBytes = %q, %v, want %q", p, err, v)
+		}
+		if !d.Done() {
+			t.Errorf("bytes left after decoding %q", v)
+		}
+	}
+
+	// Decoded bytes don't alias the buffer
+	enc := []byte{0x02, 'a', 'b'}
+	p, err := NewBuffer(enc).DecodeBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc[1] = 'x'
+	if string(p) != "ab" {
+		t.Errorf("changing the buffer changed the decoded bytes to %q", p)
+	}
+}
+
+func TestDecodeBytesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		enc  []byte
+		err  error
+	}{
+		{"no length", nil, ErrTruncated},
+		{"truncated length", []byte{0x80}, ErrTruncated},
+		{"truncated value", []byte{0x03, 'a', 'b'}, ErrTruncated},
+		{"huge length", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, ErrTruncated},
+	} {
+		if _, err := NewBuffer(tt.enc).DecodeBytes(); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	for _, tt := range []struct {
+		field int32
+		typ   Type
+		enc   []byte
+	}{
+		{1, Varint, []byte{0x08}},
+		{2, Bytes, []byte{0x12}},
+		{15, Fixed32, []byte{0x7d}},
+		{16, Fixed64, []byte{0x81, 0x01}},
+		{536870911, StartGroup, []byte{0xfb, 0xff, 0xff, 0xff, 0x0f}},
+	} {
+		b := NewBuffer(nil)
+		b.EncodeTag(tt.field, tt.typ)
+		if !bytes.Equal(b.Bytes(), tt.enc) {
+			t.Errorf("EncodeTag(%d, %d) = %x, want %x", tt.field, tt.typ, b.Bytes(), tt.enc)
+		}
+
+		field, typ, err := NewBuffer(tt.enc).DecodeTag()
+		if err != nil || field != tt.field || typ != tt.typ {
+			t.Errorf("DecodeTag(%x) = %d, %d, %v, want %d, %d", tt.enc, field, typ, err, tt.field, tt.typ)
+		}
+	}
+
+	for _, enc := range [][]byte{{0x0e}, {0x0f}} {
+		if _, _, err := NewBuffer(enc).DecodeTag(); err != ErrWireType {
+			t.Errorf("DecodeTag(%x) returned %v, want ErrWireType", enc, err)
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	b := NewBuffer(nil)
+	b.EncodeTag(1, Varint)
+	b.EncodeVarint(300)
+	b.EncodeTag(2, Fixed64)
+	b.EncodeFixed64(1)
+	b.EncodeTag(3, Fixed32)
+	b.EncodeFixed32(1)
+	b.EncodeTag(4, Bytes)
+	b.EncodeString("skipped")
+	b.EncodeTag(5, StartGroup)
+	b.EncodeTag(1, Varint)
+	b.EncodeVarint(1)
+	b.EncodeTag(6, StartGroup)
+	b.EncodeTag(6, EndGroup)
+	b.EncodeTag(5, EndGroup)
+	b.EncodeTag(7, Varint)
+	b.EncodeVarint(42)
+
+	d := NewBuffer(b.Bytes())
+	for {
+		field, typ, err := d.DecodeTag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if field == 7 {
+			break
+		}
+		if err := d.Skip(field, typ); err != nil {
+			t.Fatalf("Skip(%d, %d) returned %v", field, typ, err)
+		}
+	}
+	if v, err := d.DecodeVarint(); err != nil || v != 42 || !d.Done() {
+		t.Errorf("got %d, %v after skipping, want 42", v, err)
+	}
+}
+
+func TestSkipErrors(t *testing.T) {
+	group := func(fields ...int32) []byte {
+		b := NewBuffer(nil)
+		for _, f := range fields {
+			b.EncodeTag(f, EndGroup)
+		}
+		return b.Bytes()
+	}
+
+	for _, tt := range []struct {
+		name string
+		typ  Type
+		enc  []byte
+		err  error
+	}{
+		{"truncated varint", Varint, []byte{0x80}, ErrTruncated},
+		{"truncated fixed64", Fixed64, []byte{1, 2, 3, 4}, ErrTruncated},
+		{"truncated fixed32", Fixed32, []byte{1, 2}, ErrTruncated},
+		{"truncated bytes", Bytes, []byte{0x05, 'a'}, ErrTruncated},
+		{"unterminated group", StartGroup, nil, ErrTruncated},
+		{"mismatched end group", StartGroup, group(2), ErrWireType},
+		{"end group", EndGroup, nil, ErrWireType},
+	} {
+		if err := NewBuffer(tt.enc).Skip(1, tt.typ); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestDecodePacked(t *testing.T) {
+	packed := NewBuffer(nil)
+	for _, v := range []uint64{1, 300, math.MaxUint64} {
+		packed.EncodeVarint(v)
+	}
+	b := NewBuffer(nil)
+	b.EncodeBytes(packed.Bytes())
+
+	var got []uint64
+	err := NewBuffer(b.Bytes()).DecodePacked(func(b *Buffer) error {
+		v, err := b.DecodeVarint()
+		got = append(got, v)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 300 || got[2] != math.MaxUint64 {
+		t.Errorf("got %v, want [1 300 %d]", got, uint64(math.MaxUint64))
+	}
+
+	// A value cut off at the end of the packed field is an error,
+	// even if more bytes follow the field
+	err = NewBuffer([]byte{0x02, 0x01, 0x80, 0x01}).DecodePacked(func(b *Buffer) error {
+		_, err := b.DecodeVarint()
+		return err
+	})
+	if err != ErrTruncated {
+		t.Errorf("got %v for a truncated packed value, want ErrTruncated", err)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright (c) 2017 Johan Brandhorst
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build js && !wasm
+// +build js,!wasm
+
+package grpcweb
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// HTTPMethod is an enum for valid HTTP methods
+type HTTPMethod int
+
+func (h HTTPMethod) String() string {
+	return map[HTTPMethod]string{
+		GET:  "GET",
+		POST: "POST",
+	}[h]
+}
+
+// Define HTTP methods
+const (
+	GET = HTTPMethod(iota)
+	POST
+	// Don't really need any other methods
+)
+
+// XHRIO encapsulates the google XhrIO class.
+type XHRIO struct {
+	*js.Object
+}
+
+// SetRequestHeader sets the header key to value
+func (x *XHRIO) SetRequestHeader(key, value string) {
+	x.Get("headers").Call("set", key, value)
+}
+
+// SetTimeout sets the header key to value
+func (x *XHRIO) SetTimeout(timeout time.Duration) {
+	x.Call("setTimeoutInterval", timerMillis(timeout))
+}
+
+// Send sends the data to the endpoint using the method
+func (x *XHRIO) Send(endpoint string, method HTTPMethod, data []byte) {
+	x.Call("send", endpoint, method.String(), js.Global.Get("Uint8Array").New(data))
+}
+
+// GetResponseHeaders returns the headers of the response
+func (x *XHRIO) GetResponseHeaders() Metadata {
+	headers := x.Call("getResponseHeaders")
+	m := Metadata{}
+	for _, k := range js.Keys(headers) {
+		// Malformed binary values are dropped
+		_ = m.AppendHeader(k, headers.Get(k).String())
+	}
+
+	return m
+}
+
+// GetStatus returns the HTTP status code of the response
+func (x *XHRIO) GetStatus() int {
+	return x.Call("getStatus").Int()
+}
+
+// GetLastError returns the last error message of the request
+func (x *XHRIO) GetLastError() string {
+	return x.Call("getLastError").String()
+}
+
+// Abort closes the XHR stream
+func (x *XHRIO) Abort() {
+	x.Call("abort")
+}
+
+// NewXHRIO initializes an XHRIO object.
+func NewXHRIO() *XHRIO {
+	return &XHRIO{
+		Object: js.Global.Get("goog").Get("net").Get("XhrIo").New(),
+	}
+}
+
+// EventType is a NodeReadableStream event type
+// Defined in
+// https://github.com/google/closure-library/blob/master/closure/goog/net/streams/nodereadablestream.js#L54
+type EventType string
+
+// All the defined EventTypes
+const (
+	READABLE = EventType("readable")
+	DATA     = EventType("data")
+	END      = EventType("end")
+	CLOSE    = EventType("close")
+	ERROR    = EventType("error")
+)
+
+// XHRNodeReadableStream encapsulates a google
+// XhrNodeReadableStream class
+type XHRNodeReadableStream struct {
+	*js.Object
+	xhr *XHRIO
+}
+
+// NewXHRNodeReadableStream initializes an
+// XHRNodeReadableStream object with the provided XhrIO.
+func NewXHRNodeReadableStream(xhrIO *XHRIO) *XHRNodeReadableStream {
+	xhrnrs := &XHRNodeReadableStream{
+		Object: js.Global.Get("goog").Get("net").Get("streams").Call("createXhrNodeReadableStream", xhrIO.Object),
+	}
+	xhrnrs.xhr = xhrIO
+
+	return xhrnrs
+}
+
+// On sets the callback handler for the given event
+func (x *XHRNodeReadableStream) On(event EventType, callback func(*js.Object)) {
+	x.Call("on", string(event), callback)
+}
+
+// Abort closes the stream
+func (x *XHRNodeReadableStream) Abort() {
+	x.xhr.Abort()
+}
+
+// xhrTransport is a Transport using the goog.net.XhrIo class,
+// speaking the gRPC-gateway streaming protocol.
+type xhrTransport struct{}
+
+// NewXHRTransport returns a Transport that sends requests
+// with XHR, using the gRPC-gateway streaming protocol.
+func NewXHRTransport() Transport {
+	return xhrTransport{}
+}
+
+// NewStream implements Transport.
+func (xhrTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	xhr := NewXHRIO()
+	s := &xhrStream{
+		xhr:    xhr,
+		stream: NewXHRNodeReadableStream(xhr),
+		frames: newFrameQueue(),
+	}
+
+	// SetRequestHeader replaces any previous value,
+	// so repeated values are joined into one header.
+	encoded := map[string][]string{}
+	headerValues(req.Header, func(k, v string) {
+		encoded[k] = append(encoded[k], v)
+	})
+	for k, v := range encoded {
+		xhr.SetRequestHeader(k, strings.Join(v, ", "))
+	}
+
+	s.stream.On(DATA, func(obj *js.Object) {
+		if m := obj.Get("1"); m != js.Undefined {
+			s.frames.put(&Frame{
+				Message: js.Global.Get("Uint8Array").New(m).Interface().([]byte),
+			})
+		}
+		if st := obj.Get("2"); st != js.Undefined {
+			status, err := ParseRPCStatus(js.Global.Get("Uint8Array").New(st).Interface().([]byte))
+			if err != nil {
+				s.frames.fail(&Error{Code: Internal, Message: err.Error(), Err: err})
+				return
+			}
+			s.frames.put(&Frame{Status: status})
+		}
+	})
+	s.stream.On(ERROR, func(_ *js.Object) {
+		s.frames.fail(&Error{Code: FromHTTPStatus(xhr.GetStatus()), Message: xhr.GetLastError()})
+	})
+	s.stream.On(CLOSE, func(_ *js.Object) {
+		s.frames.fail(&Error{Code: Cancelled, Message: "stream cancelled"})
+	})
+	s.stream.On(END, func(_ *js.Object) {
+		// Only has an effect if no status was received
+		s.frames.fail(&Error{Code: Internal, Message: "stream ended without a status"})
+	})
+
+	xhr.SetRequestHeader("Content-Type", "application/x-protobuf")
+	xhr.SetRequestHeader("X-Accept-Content-Transfer-Encoding", "base64")
+	xhr.SetRequestHeader("X-Accept-Response-Streaming", "true")
+	if req.Timeout > 0 {
+		xhr.SetTimeout(req.Timeout)
+	}
+
+	xhr.Send(req.Endpoint, POST, req.Body)
+
+	return s, nil
+}
+
+// xhrStream is the TransportStream of an xhrTransport.
+type xhrStream struct {
+	xhr    *XHRIO
+	stream *XHRNodeReadableStream
+	frames *frameQueue
+}
+
+func (s *xhrStream) Header() Metadata {
+	return s.xhr.GetResponseHeaders()
+}
+
+func (s *xhrStream) Recv() (*Frame, error) {
+	return s.frames.get()
+}
+
+func (s *xhrStream) Close() {
+	s.stream.Abort()
+}
+
+// grpcWebXHRTransport is a Transport using the XMLHttpRequest
+// class, speaking the gRPC-Web protocol.
+type grpcWebXHRTransport struct{}
+
+// NewGRPCWebXHRTransport returns a Transport that sends requests
+// with XHR, using the gRPC-Web protocol.
+func NewGRPCWebXHRTransport() Transport {
+	return grpcWebXHRTransport{}
+}
+
+// defaultGRPCWebTransport returns the Transport
+// used by GRPCWebClientBase unless configured otherwise.
+func defaultGRPCWebTransport() Transport {
+	if js.Global.Get("XMLHttpRequest") == js.Undefined && nodeSupported() {
+		return NewNodeTransport()
+	}
+
+	return NewGRPCWebXHRTransport()
+}
+
+// defaultGatewayTransport returns the Transport
+// used by GatewayClientBase unless configured otherwise.
+func defaultGatewayTransport() Transport {
+	if js.Global.Get("XMLHttpRequest") == js.Undefined && nodeSupported() {
+		return NewNodeGatewayTransport()
+	}
+
+	return NewXHRTransport()
+}
+
+// NewStream implements Transport.
+func (grpcWebXHRTransport) NewStream(req *TransportRequest) (TransportStream, error) {
+	contentType, body, err := encodeBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	xhr := js.Global.Get("XMLHttpRequest").New()
+	s := &grpcWebXHRStream{
+		xhr:     xhr,
+		frames:  newFrameQueue(),
+		decoder: frameDecoder{maxMsgSize: req.MaxRecvMsgSize},
+	}
+
+	xhr.Call("open", POST.String(), req.Endpoint)
+	// Lets us read the binary response incrementally from responseText
+	xhr.Call("overrideMimeType", "text/plain; charset=x-user-defined")
+
+	headerValues(req.Header, func(k, v string) {
+		xhr.Call("setRequestHeader", k, v)
+	})
+	xhr.Call("setRequestHeader", "Content-Type", contentType)
+	xhr.Call("setRequestHeader", "Accept", contentType)
+	xhr.Call("setRequestHeader", "X-Grpc-Web", "1")
+	compressionHeaders(req.Compressor, func(k, v string) {
+		xhr.Call("setRequestHeader", k, v)
+	})
+	if req.Timeout > 0 {
+		xhr.Set("timeout", timerMillis(req.Timeout))
+		xhr.Call("setRequestHeader", "grpc-timeout", encodeTimeout(req.Timeout))
+	}
+
+	xhr.Set("onprogress", func(_ *js.Object) {
+		s.read()
+	})
+	xhr.Set("onload", func(_ *js.Object) {
+		s.read()
+		s.end()
+	})
+	xhr.Set("onerror", func(_ *js.Object) {
+		s.frames.fail(&Error{Code: Unavailable, Message: "network error"})
+	})
+	xhr.Set("ontimeout", func(_ *js.Object) {
+		s.frames.fail(&Error{Code: DeadlineExceeded, Message: "request timed out"})
+	})
+	xhr.Set("onabort", func(_ *js.Object) {
+		s.frames.fail(&Error{Code: Cancelled, Message: "stream cancelled"})
+	})
+
+	if req.Encoding == TextEncoding {
+		xhr.Call("send", string(body))
+	} else {
+		xhr.Call("send", js.Global.Get("Uint8Array").New(body))
+	}
+
+	return s, nil
+}
+
+// grpcWebXHRStream is the TransportStream of a grpcWebXHRTransport.
+type grpcWebXHRStream struct {
+	xhr     *js.Object
+	frames  *frameQueue
+	decoder frameDecoder
+	// offset is the number of characters of responseText read so far
+	offset  int
+	started bool
+	// text is set if the response uses TextEncoding
+	text    bool
+	textDec base64Decoder
+}
+
+// read decodes the part of the response received since the last read.
+func (s *grpcWebXHRStream) read() {
+	text := s.xhr.Get("responseText").Call("substr", s.offset)
+	s.offset += text.Length()
+
+	// Each character holds a single byte of the response
+	// in its low 8 bits, see overrideMimeType above.
+	var chunk []byte
+	for _, r := range text.String() {
+		chunk = append(chunk, byte(r))
+	}
+
+	if !s.started {
+		// The response headers are available from the first read
+		s.started = true
+		s.text = isTextContentType(s.xhr.Call("getResponseHeader", "Content-Type").String())
+	}
+
+	var err error
+	if s.text {
+		chunk, err = s.textDec.decode(chunk)
+	}
+
+	frames, ferr := s.decoder.decode(chunk)
+	for _, f := range frames {
+		s.frames.put(f)
+	}
+	if err == nil {
+		err = ferr
+	}
+	if err != nil {
+		s.frames.fail(err)
+		s.xhr.Call("abort")
+	}
+}
+
+// end is called once the whole response has been received.
+// It only has an effect if no trailer frame was received.
+func (s *grpcWebXHRStream) end() {
+	// Trailers-only responses carry the status in the headers
+	if status, ok := statusFromMetadata(s.Header()); ok {
+		s.frames.put(&Frame{Status: status})
+		return
+	}
+
+	if code := s.xhr.Get("status").Int(); code != 200 {
+		s.frames.fail(&Error{Code: FromHTTPStatus(code), Message: s.xhr.Get("statusText").String()})
+		return
+	}
+
+	s.frames.fail(&Error{Code: Internal, Message: "response ended without a status"})
+}
+
+func (s *grpcWebXHRStream) Header() Metadata {
+	return parseHeaderBlock([]byte(s.xhr.Call("getAllResponseHeaders").String()))
+}
+
+func (s *grpcWebXHRStream) Recv() (*Frame, error) {
+	return s.frames.get()
+}
+
+func (s *grpcWebXHRStream) Close() {
+	s.xhr.Call("abort")
+}